@@ -0,0 +1,29 @@
+// Package bodylimit caps the size of request bodies, so a client can't make
+// the gateway buffer arbitrarily large requests.
+package bodylimit
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+
+	"git.neds.sh/matty/entain/api/problem"
+)
+
+// Middleware rejects requests declaring a body over max bytes with 413 Request
+// Entity Too Large. Bodies of unknown length are cut off at max, which fails
+// reading them once it's exceeded.
+func Middleware(max int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			w.Header().Set("Connection", "close")
+			problem.Write(w, r, problem.New(http.StatusRequestEntityTooLarge, problem.CodeName(codes.ResourceExhausted),
+				fmt.Sprintf("request body larger than %d bytes", max)))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,51 @@
+package bodylimit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		unknownLength bool
+		wantStatus    int
+		wantReadErr   bool
+	}{
+		{name: "empty", wantStatus: http.StatusOK},
+		{name: "at limit", body: "0123456789", wantStatus: http.StatusOK},
+		{name: "declared over limit", body: "0123456789a", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "unknown length over limit", body: "0123456789a", unknownLength: true, wantStatus: http.StatusOK, wantReadErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readErr error
+			handler := Middleware(10, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, readErr = ioutil.ReadAll(r.Body)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/racing.Racing/ListRaces", strings.NewReader(tt.body))
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusRequestEntityTooLarge && rec.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want problem details", rec.Header().Get("Content-Type"))
+			}
+			if (readErr != nil) != tt.wantReadErr {
+				t.Errorf("read error = %v, want error %v", readErr, tt.wantReadErr)
+			}
+		})
+	}
+}
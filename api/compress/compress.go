@@ -0,0 +1,311 @@
+// Package compress compresses responses with brotli or gzip, for clients that
+// accept them.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encodings, in order of preference when a client accepts several equally.
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// DefaultContentTypes are the types compressed when Options doesn't list any.
+// Everything else, e.g. images and gRPC-Web, is already compact or compressed.
+var DefaultContentTypes = []string{
+	"application/json",
+	"application/javascript",
+	"image/svg+xml",
+	"text/",
+}
+
+// Options configures a Compressor.
+type Options struct {
+	// MinSize is the smallest response compressed, below which compression
+	// costs more than it saves.
+	MinSize int
+	// ContentTypes lists the media types to compress. Entries ending in "/"
+	// match every subtype, e.g. "text/".
+	ContentTypes []string
+}
+
+// Compressor compresses responses.
+type Compressor struct {
+	opts Options
+}
+
+// NewCompressor creates a new compressor.
+func NewCompressor(opts Options) *Compressor {
+	if len(opts.ContentTypes) == 0 {
+		opts.ContentTypes = DefaultContentTypes
+	}
+
+	return &Compressor{opts: opts}
+}
+
+// Middleware compresses responses of compressible types and at least MinSize
+// bytes, with the encoding the client prefers. Responses are held back until
+// MinSize bytes are written, the handler flushes, or it returns, whichever is
+// first, and then streamed.
+func (c *Compressor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses differ by encoding, so caches must key them on it.
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &responseWriter{ResponseWriter: w, compressor: c, encoding: encoding, status: http.StatusOK}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Negotiate returns the supported encoding an Accept-Encoding header prefers,
+// or "" if it accepts none of them.
+func Negotiate(acceptEncoding string) string {
+	qualities := map[string]float64{}
+	wildcard := -1.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseEncoding(part)
+		switch name {
+		case "":
+		case "*":
+			wildcard = q
+		default:
+			qualities[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{EncodingBrotli, EncodingGzip} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+// parseEncoding parses one entry of an Accept-Encoding header, e.g. "gzip;q=0.8".
+func parseEncoding(s string) (string, float64) {
+	params := strings.Split(s, ";")
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			v, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil {
+				return "", 0
+			}
+			q = v
+		}
+	}
+
+	return name, q
+}
+
+// compressible reports whether responses of contentType are worth compressing.
+func (c *Compressor) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range c.opts.ContentTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	gzipWriters   = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	brotliWriters = sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }}
+)
+
+// encoder is implemented by both gzip and brotli writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+func newEncoder(encoding string, w io.Writer) encoder {
+	var enc encoder
+	if encoding == EncodingBrotli {
+		enc = brotliWriters.Get().(*brotli.Writer)
+	} else {
+		enc = gzipWriters.Get().(*gzip.Writer)
+	}
+
+	enc.Reset(w)
+	return enc
+}
+
+func releaseEncoder(enc encoder) {
+	enc.Reset(nil)
+
+	switch enc := enc.(type) {
+	case *brotli.Writer:
+		brotliWriters.Put(enc)
+	case *gzip.Writer:
+		gzipWriters.Put(enc)
+	}
+}
+
+// responseWriter buffers the start of a response until it can decide whether
+// to compress it.
+type responseWriter struct {
+	http.ResponseWriter
+	compressor *Compressor
+	encoding   string
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	enc         encoder
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+
+	// Informational responses go straight out, and the real one follows.
+	if status >= 100 && status < 200 {
+		w.wroteHeader = false
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.compressor.opts.MinSize {
+			return len(b), nil
+		}
+
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends everything written so far, compressing it if the response is
+// compressible whatever its size, since more is presumably on its way.
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide writes the header, compressing the response if large says it's big
+// enough and everything else allows, followed by the buffered body.
+func (w *responseWriter) decide(large bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) != 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if large && w.shouldCompress() {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// The compressed body isn't byte for byte the one tagged.
+			h.Set("ETag", "W/"+etag)
+		}
+
+		w.enc = newEncoder(w.encoding, w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *responseWriter) shouldCompress() bool {
+	h := w.Header()
+
+	switch {
+	case w.status < 200, w.status == http.StatusNoContent, w.status == http.StatusNotModified, w.status == http.StatusPartialContent:
+		return false
+	case h.Get("Content-Encoding") != "", h.Get("Content-Range") != "":
+		return false
+	}
+
+	return w.compressor.compressible(h.Get("Content-Type"))
+}
+
+// close finishes the response once the handler returns.
+func (w *responseWriter) close() {
+	if !w.decided {
+		if !w.wroteHeader {
+			// Nothing was written, which net/http answers with an empty 200.
+			return
+		}
+		_ = w.decide(len(w.buf) >= w.compressor.opts.MinSize)
+	}
+
+	if w.enc != nil {
+		_ = w.enc.Close()
+		releaseEncoder(w.enc)
+		w.enc = nil
+	}
+}
@@ -0,0 +1,215 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: "identity", want: ""},
+		{in: "gzip", want: EncodingGzip},
+		{in: "gzip, deflate, br", want: EncodingBrotli},
+		{in: "br;q=0.5, gzip", want: EncodingGzip},
+		{in: "br;q=0, gzip;q=0", want: ""},
+		{in: "*", want: EncodingBrotli},
+		{in: "br;q=0, *;q=0.1", want: EncodingGzip},
+		{in: "GZIP;q=0.9", want: EncodingGzip},
+		{in: "gzip;q=nope", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Negotiate(tt.in); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompressor_Middleware(t *testing.T) {
+	large := strings.Repeat(`{"name":"Race"}`, 100)
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		etag           string
+		status         int
+		body           string
+		wantEncoding   string
+		wantETag       string
+	}{
+		{
+			name:           "gzip",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			etag:           `"abc"`,
+			body:           large,
+			wantEncoding:   EncodingGzip,
+			wantETag:       `W/"abc"`,
+		},
+		{
+			name:           "brotli",
+			acceptEncoding: "gzip, br",
+			contentType:    "application/json",
+			body:           large,
+			wantEncoding:   EncodingBrotli,
+		},
+		{
+			name:           "sniffed type",
+			acceptEncoding: "gzip",
+			body:           "<html>" + large,
+			wantEncoding:   EncodingGzip,
+		},
+		{
+			name:        "not accepted",
+			contentType: "application/json",
+			etag:        `"abc"`,
+			body:        large,
+			wantETag:    `"abc"`,
+		},
+		{
+			name:           "too small",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           `{"name":"Race"}`,
+		},
+		{
+			name:           "incompressible type",
+			acceptEncoding: "gzip",
+			contentType:    "application/grpc-web+proto",
+			body:           large,
+		},
+		{
+			name:           "not modified",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			status:         http.StatusNotModified,
+		},
+		{
+			name:           "head",
+			method:         http.MethodHead,
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+		},
+	}
+
+	compressor := NewCompressor(Options{MinSize: 100})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := compressor.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+
+				// In pieces, to exercise the buffering.
+				for body := tt.body; body != ""; {
+					n := len(body)
+					if n > 40 {
+						n = 40
+					}
+					_, _ = io.WriteString(w, body[:n])
+					body = body[n:]
+				}
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, "/v1/races", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			wantStatus := tt.status
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if rec.Code != wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, wantStatus)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+
+			if got := decode(t, tt.wantEncoding, rec.Body); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestCompressor_Middleware_flush(t *testing.T) {
+	handler := NewCompressor(Options{MinSize: 1024}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, "data: 2\n\n")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Errorf("response wasn't flushed")
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != EncodingGzip {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := decode(t, EncodingGzip, rec.Body); got != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func decode(t *testing.T, encoding string, r io.Reader) string {
+	t.Helper()
+
+	var err error
+	switch encoding {
+	case EncodingGzip:
+		if r, err = gzip.NewReader(r); err != nil {
+			t.Fatal(err)
+		}
+	case EncodingBrotli:
+		r = brotli.NewReader(r)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/api/cors"
+	"git.neds.sh/matty/entain/api/grpcclient"
+	"git.neds.sh/matty/entain/api/ratelimit"
+	"git.neds.sh/matty/entain/pkg/settings"
+)
+
+// EnvPrefix prefixes the environment variable of every setting, e.g. API_GRPC_ENDPOINT.
+const EnvPrefix = "API"
+
+// minGRPCKeepaliveTime is the shortest keepalive the racing and sports services'
+// enforcement policies permit.
+const minGRPCKeepaliveTime = 10 * time.Second
+
+// Config holds the api gateway's configuration.
+type Config struct {
+	APIEndpoint     string
+	GRPCEndpoint    string
+	MetricsEndpoint string
+
+	SportsGRPCEndpoint string
+
+	TLSCertFile string
+	TLSKeyFile  string
+
+	GRPCTLS        bool
+	GRPCCAFile     string
+	GRPCCertFile   string
+	GRPCKeyFile    string
+	GRPCServerName string
+
+	GRPCRetryAttempts    int
+	GRPCKeepaliveTime    time.Duration
+	GRPCKeepaliveTimeout time.Duration
+	GRPCBreakerFailures  int
+	GRPCBreakerCooldown  time.Duration
+
+	ResponseMaxAge       time.Duration
+	ResponseCacheEnabled bool
+	ResponseCacheSize    int
+
+	UpcomingTimeout time.Duration
+
+	WebRPCEnabled      bool
+	CORSAllowedOrigins string
+
+	CompressionEnabled     bool
+	CompressionMinSize     int
+	MaxRequestBody         int
+	SecurityHeadersEnabled bool
+	HSTSMaxAge             time.Duration
+
+	RateLimitEnabled        bool
+	RateLimitDefault        string
+	RateLimitRoutes         string
+	RateLimitTrustedProxies int
+	RateLimitAPIKeysFile    string
+
+	AuthHMACSecret string
+	AuthJWKSFile   string
+
+	ReadinessTimeout time.Duration
+	ShutdownDelay    time.Duration
+	ShutdownTimeout  time.Duration
+
+	settings.Telemetry
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		APIEndpoint:            "localhost:8000",
+		GRPCEndpoint:           "localhost:9000",
+		MetricsEndpoint:        "localhost:8100",
+		SportsGRPCEndpoint:     "localhost:10000",
+		GRPCRetryAttempts:      3,
+		GRPCKeepaliveTime:      30 * time.Second,
+		GRPCKeepaliveTimeout:   10 * time.Second,
+		GRPCBreakerFailures:    5,
+		GRPCBreakerCooldown:    10 * time.Second,
+		ResponseMaxAge:         30 * time.Second,
+		ResponseCacheSize:      1000,
+		UpcomingTimeout:        2 * time.Second,
+		WebRPCEnabled:          true,
+		CompressionEnabled:     true,
+		CompressionMinSize:     1024,
+		MaxRequestBody:         1 << 20,
+		SecurityHeadersEnabled: true,
+		HSTSMaxAge:             365 * 24 * time.Hour,
+		RateLimitDefault:       "600/1m",
+		ReadinessTimeout:       time.Second,
+		ShutdownTimeout:        15 * time.Second,
+		Telemetry:              settings.DefaultTelemetry(),
+	}
+}
+
+// Settings returns the settings of c, each reading and writing its field.
+func (c *Config) Settings() []settings.Setting {
+	s := []settings.Setting{
+		{Name: "api-endpoint", Usage: "API endpoint", Value: settings.String(&c.APIEndpoint)},
+		{Name: "grpc-endpoint", Usage: "gRPC server endpoint: a host:port, a comma separated list of them, or a target such as dns:///racing:9000, balanced round robin across replicas", Value: settings.String(&c.GRPCEndpoint)},
+		{Name: "metrics-endpoint", Usage: "Prometheus metrics endpoint", Value: settings.String(&c.MetricsEndpoint)},
+		{Name: "sports-grpc-endpoint", Usage: "Sports gRPC server endpoint /v1/upcoming lists events from, in the same forms as grpc-endpoint, or empty to list races only", Value: settings.String(&c.SportsGRPCEndpoint)},
+		{Name: "tls-cert-file", Usage: "PEM certificate to serve the API over HTTPS with, reloaded when it changes", Value: settings.String(&c.TLSCertFile)},
+		{Name: "tls-key-file", Usage: "PEM private key for tls-cert-file", Value: settings.String(&c.TLSKeyFile)},
+		{Name: "grpc-tls", Usage: "Connect to the gRPC server over TLS", Value: settings.Bool(&c.GRPCTLS)},
+		{Name: "grpc-ca-file", Usage: "PEM CA bundle to verify the gRPC server with, instead of the system roots", Value: settings.String(&c.GRPCCAFile)},
+		{Name: "grpc-cert-file", Usage: "PEM client certificate presented to the gRPC server for mutual TLS", Value: settings.String(&c.GRPCCertFile)},
+		{Name: "grpc-key-file", Usage: "PEM private key for grpc-cert-file", Value: settings.String(&c.GRPCKeyFile)},
+		{Name: "grpc-server-name", Usage: "Name to verify the gRPC server certificate against, defaulting to the grpc-endpoint host", Value: settings.String(&c.GRPCServerName)},
+		{Name: "grpc-retry-attempts", Usage: "Most attempts made at an idempotent gRPC call failing with Unavailable, or 1 to not retry", Value: settings.Int(&c.GRPCRetryAttempts)},
+		{Name: "grpc-keepalive-time", Usage: "Idle time after which the gRPC connection is pinged to check it's alive, at least 10s", Value: settings.Duration(&c.GRPCKeepaliveTime)},
+		{Name: "grpc-keepalive-timeout", Usage: "Time to wait for a keepalive ping to be answered before closing the gRPC connection", Value: settings.Duration(&c.GRPCKeepaliveTimeout)},
+		{Name: "grpc-breaker-failures", Usage: "Consecutive Unavailable gRPC calls that open the circuit breaker, or 0 to disable it", Value: settings.Int(&c.GRPCBreakerFailures)},
+		{Name: "grpc-breaker-cooldown", Usage: "Time the circuit breaker fails calls fast for before letting a probe call through", Value: settings.Duration(&c.GRPCBreakerCooldown)},
+		{Name: "response-max-age", Usage: "Longest Cache-Control max-age given to race responses, which is shortened so it never outlives the next race to jump", Value: settings.Duration(&c.ResponseMaxAge)},
+		{Name: "response-cache-enabled", Usage: "Serve repeated anonymous race queries from an in-process cache", Value: settings.Bool(&c.ResponseCacheEnabled)},
+		{Name: "response-cache-size", Usage: "Maximum number of cached responses", Value: settings.Int(&c.ResponseCacheSize)},
+		{Name: "upcoming-timeout", Usage: "Time each backend gets to answer /v1/upcoming before its items are left out", Value: settings.Duration(&c.UpcomingTimeout)},
+		{Name: "web-rpc-enabled", Usage: "Serve the racing RPCs over gRPC-Web and the Connect protocol, at /racing.Racing/<method>", Value: settings.Bool(&c.WebRPCEnabled)},
+		{Name: "cors-allowed-origins", Usage: "Origins browsers may call the API from, e.g. https://tools.example.com,http://localhost:3000, or * for any", Value: settings.String(&c.CORSAllowedOrigins)},
+		{Name: "compression-enabled", Usage: "Compress responses with brotli or gzip for clients that accept them", Value: settings.Bool(&c.CompressionEnabled)},
+		{Name: "compression-min-size", Usage: "Smallest response in bytes worth compressing", Value: settings.Int(&c.CompressionMinSize)},
+		{Name: "max-request-body", Usage: "Largest request body in bytes accepted", Value: settings.Int(&c.MaxRequestBody)},
+		{Name: "security-headers-enabled", Usage: "Set security headers such as Content-Security-Policy and X-Content-Type-Options on responses", Value: settings.Bool(&c.SecurityHeadersEnabled)},
+		{Name: "hsts-max-age", Usage: "Strict-Transport-Security max-age sent over HTTPS, or 0 to not send it", Value: settings.Duration(&c.HSTSMaxAge)},
+		{Name: "rate-limit-enabled", Usage: "Rate limit requests per client", Value: settings.Bool(&c.RateLimitEnabled)},
+		{Name: "rate-limit-default", Usage: "Requests allowed per client and period on routes without their own limit, e.g. 600/1m", Value: settings.String(&c.RateLimitDefault)},
+		{Name: "rate-limit-routes", Usage: "Per-route limits, e.g. /v1/list-races=20/1s,/v1/race-stats=5/1s", Value: settings.String(&c.RateLimitRoutes)},
+		{Name: "rate-limit-trusted-proxies", Usage: "Number of proxies in front of the gateway appending to X-Forwarded-For, which rate limited clients are identified by when set", Value: settings.Int(&c.RateLimitTrustedProxies)},
+		{Name: "rate-limit-api-keys-file", Usage: "YAML file mapping client names to the API keys they send in X-Api-Key, each client rate limited as one", Value: settings.String(&c.RateLimitAPIKeysFile)},
+		{Name: "auth-hmac-secret", Usage: "Shared secret verifying HS256 bearer tokens, so callers presenting one are rate limited by subject", Secret: true, Value: settings.String(&c.AuthHMACSecret)},
+		{Name: "auth-jwks-file", Usage: "JWKS file of RSA public keys verifying RS256 bearer tokens, so callers presenting one are rate limited by subject", Value: settings.String(&c.AuthJWKSFile)},
+		{Name: "readiness-timeout", Usage: "Timeout for upstream health checks made by /readyz", Value: settings.Duration(&c.ReadinessTimeout)},
+		{Name: "shutdown-delay", Usage: "Time to keep serving with failing readiness before draining on shutdown", Value: settings.Duration(&c.ShutdownDelay)},
+		{Name: "shutdown-timeout", Usage: "Time allowed for in-flight requests to drain on shutdown", Value: settings.Duration(&c.ShutdownTimeout)},
+	}
+
+	return append(s, c.Telemetry.Settings()...)
+}
+
+// Validate checks the configuration is usable, reporting every problem found.
+func (c *Config) Validate() error {
+	var p settings.Problems
+
+	p.Endpoint("api-endpoint", c.APIEndpoint)
+	p.Endpoint("metrics-endpoint", c.MetricsEndpoint)
+
+	p.Check("grpc-endpoint", grpcclient.ValidateEndpoint(c.GRPCEndpoint))
+	if c.SportsGRPCEndpoint != "" {
+		p.Check("sports-grpc-endpoint", grpcclient.ValidateEndpoint(c.SportsGRPCEndpoint))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		p.Addf("tls-cert-file", "must be set together with tls-key-file")
+	}
+	if (c.GRPCCertFile == "") != (c.GRPCKeyFile == "") {
+		p.Addf("grpc-cert-file", "must be set together with grpc-key-file")
+	}
+	if !c.GRPCTLS && (c.GRPCCAFile != "" || c.GRPCCertFile != "" || c.GRPCServerName != "") {
+		p.Addf("grpc-tls", "must be enabled to use grpc-ca-file, grpc-cert-file or grpc-server-name")
+	}
+
+	if c.GRPCRetryAttempts < 1 || c.GRPCRetryAttempts > 5 {
+		p.Addf("grpc-retry-attempts", "must be between 1 and 5")
+	}
+	if c.GRPCBreakerFailures < 0 {
+		p.Addf("grpc-breaker-failures", "must not be negative")
+	}
+
+	p.NotNegative("response-max-age", c.ResponseMaxAge)
+	if c.ResponseCacheEnabled && c.ResponseCacheSize <= 0 {
+		p.Addf("response-cache-size", "must be positive")
+	}
+
+	_, err := cors.ParseOrigins(c.CORSAllowedOrigins)
+	p.Check("cors-allowed-origins", err)
+
+	if c.CompressionMinSize < 0 {
+		p.Addf("compression-min-size", "must not be negative")
+	}
+	if c.MaxRequestBody <= 0 {
+		p.Addf("max-request-body", "must be positive")
+	}
+	p.NotNegative("hsts-max-age", c.HSTSMaxAge)
+
+	_, err = ratelimit.ParseLimit(c.RateLimitDefault)
+	p.Check("rate-limit-default", err)
+	_, err = ratelimit.ParseRouteLimits(c.RateLimitRoutes)
+	p.Check("rate-limit-routes", err)
+	if c.RateLimitTrustedProxies < 0 {
+		p.Addf("rate-limit-trusted-proxies", "must not be negative")
+	}
+
+	p.Positive("grpc-keepalive-timeout", c.GRPCKeepaliveTimeout)
+	p.Positive("grpc-breaker-cooldown", c.GRPCBreakerCooldown)
+	p.Positive("upcoming-timeout", c.UpcomingTimeout)
+	p.Positive("readiness-timeout", c.ReadinessTimeout)
+	p.Positive("shutdown-timeout", c.ShutdownTimeout)
+	p.NotNegative("shutdown-delay", c.ShutdownDelay)
+	if c.GRPCKeepaliveTime < minGRPCKeepaliveTime {
+		p.Addf("grpc-keepalive-time", "must be at least %s, as the racing and sports services close connections pinged more often", minGRPCKeepaliveTime)
+	}
+
+	c.Telemetry.Check(&p)
+
+	return p.Err()
+}
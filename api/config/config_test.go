@@ -0,0 +1,80 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/settings"
+)
+
+// load builds a Config from args the way main does.
+func load(args []string) (*Config, bool, error) {
+	cfg := Default()
+	printConfig, err := settings.LoadConfig(args, EnvPrefix, cfg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return cfg, printConfig, nil
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.yaml")
+	if err := ioutil.WriteFile(path, []byte("grpc-endpoint: racing:9000\nreadiness-timeout: 2s\nlog-level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("API_READINESS_TIMEOUT", "3s")
+	os.Setenv("API_LOG_LEVEL", "warn")
+	defer os.Unsetenv("API_READINESS_TIMEOUT")
+	defer os.Unsetenv("API_LOG_LEVEL")
+
+	cfg, _, err := load([]string{"api", "--config", path, "--log-level", "error"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default", cfg.APIEndpoint, "localhost:8000"},
+		{"file", cfg.GRPCEndpoint, "racing:9000"},
+		{"env over file", cfg.ReadinessTimeout, 3 * time.Second},
+		{"flag over env", cfg.LogLevel, "error"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if _, _, err := load([]string{"api", "--api-endpoint", "nope"}); err == nil {
+		t.Errorf("Load() with invalid api-endpoint succeeded, want error")
+	}
+	if _, _, err := load([]string{"api", "--cors-allowed-origins", "tools.example.com"}); err == nil {
+		t.Errorf("Load() with an origin missing its scheme succeeded, want error")
+	}
+	if _, _, err := load([]string{"api", "--max-request-body", "0"}); err == nil {
+		t.Errorf("Load() with a max-request-body of 0 succeeded, want error")
+	}
+	if _, _, err := load([]string{"api", "--grpc-endpoint", "racing-1:9000,racing-2"}); err == nil {
+		t.Errorf("Load() with a grpc-endpoint list missing a port succeeded, want error")
+	}
+	if _, _, err := load([]string{"api", "--sports-grpc-endpoint", "sports"}); err == nil {
+		t.Errorf("Load() with a sports-grpc-endpoint missing a port succeeded, want error")
+	}
+	if _, _, err := load([]string{"api", "--sports-grpc-endpoint", ""}); err != nil {
+		t.Errorf("Load() with no sports-grpc-endpoint error = %v, want nil", err)
+	}
+	if _, _, err := load([]string{"api", "--grpc-keepalive-time", "5s"}); err == nil {
+		t.Errorf("Load() with a grpc-keepalive-time below the racing service's minimum succeeded, want error")
+	}
+	if _, _, err := load([]string{"api", "--grpc-ca-file", "ca.pem"}); err == nil {
+		t.Errorf("Load() with grpc-ca-file but not grpc-tls succeeded, want error")
+	}
+}
@@ -0,0 +1,120 @@
+// Package cors lets browsers on other origins call the API, by answering CORS
+// preflight requests and marking responses to allowed origins as shareable.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures a Policy.
+type Options struct {
+	// AllowedOrigins lists the origins allowed to call the API, e.g.
+	// https://tools.example.com. A single "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods lists the methods cross-origin requests may use.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers cross-origin requests may set.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers scripts may read.
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// Policy applies a CORS policy to requests.
+type Policy struct {
+	opts    Options
+	any     bool
+	origins map[string]bool
+}
+
+// NewPolicy creates a new policy allowing the given origins.
+func NewPolicy(opts Options) *Policy {
+	p := &Policy{opts: opts, origins: make(map[string]bool, len(opts.AllowedOrigins))}
+	for _, origin := range opts.AllowedOrigins {
+		if origin == "*" {
+			p.any = true
+		}
+		p.origins[strings.ToLower(origin)] = true
+	}
+
+	return p
+}
+
+// ParseOrigins parses a comma separated list of origins, e.g.
+// "https://tools.example.com,http://localhost:3000", or "*" for any origin.
+func ParseOrigins(s string) ([]string, error) {
+	var origins []string
+
+	for _, origin := range strings.Split(s, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+
+		if origin != "*" {
+			u, err := url.Parse(origin)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+				return nil, fmt.Errorf("origin %q: want <scheme>://<host>[:<port>]", origin)
+			}
+		}
+
+		origins = append(origins, origin)
+	}
+
+	return origins, nil
+}
+
+// Middleware answers preflight requests from allowed origins itself, rejecting
+// those from any other origin with 403 Forbidden. Other requests are passed on
+// to next, with CORS headers added for allowed origins.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Responses differ by origin, so caches must key them on it.
+		w.Header().Add("Vary", "Origin")
+
+		allowed := p.any || p.origins[strings.ToLower(origin)]
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			if !allowed {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Methods", strings.Join(p.opts.AllowedMethods, ", "))
+			if len(p.opts.AllowedHeaders) != 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(p.opts.AllowedHeaders, ", "))
+			}
+			if p.opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.opts.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if len(p.opts.ExposedHeaders) != 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.opts.ExposedHeaders, ", "))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
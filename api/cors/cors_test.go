@@ -0,0 +1,139 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseOrigins(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "*", want: 1},
+		{in: "https://tools.example.com, http://localhost:3000", want: 2},
+		{in: "tools.example.com", wantErr: true},
+		{in: "https://tools.example.com/app", wantErr: true},
+		{in: "ftp://tools.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOrigins(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOrigins(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if !tt.wantErr && len(got) != tt.want {
+			t.Errorf("ParseOrigins(%q) = %v, want %d origins", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Middleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	policy := NewPolicy(Options{
+		AllowedOrigins: []string{"https://tools.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"Grpc-Status"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	tests := []struct {
+		name         string
+		method       string
+		origin       string
+		preflight    bool
+		wantStatus   int
+		wantOrigin   string
+		wantHeaders  string
+		wantExposed  string
+		wantMaxAge   string
+		wantPassedOn bool
+	}{
+		{
+			name:         "same origin",
+			method:       http.MethodGet,
+			wantStatus:   http.StatusOK,
+			wantPassedOn: true,
+		},
+		{
+			name:         "allowed origin",
+			method:       http.MethodPost,
+			origin:       "https://tools.example.com",
+			wantStatus:   http.StatusOK,
+			wantOrigin:   "https://tools.example.com",
+			wantExposed:  "Grpc-Status",
+			wantPassedOn: true,
+		},
+		{
+			name:         "other origin",
+			method:       http.MethodPost,
+			origin:       "https://evil.example.com",
+			wantStatus:   http.StatusOK,
+			wantPassedOn: true,
+		},
+		{
+			name:        "allowed preflight",
+			method:      http.MethodOptions,
+			origin:      "https://tools.example.com",
+			preflight:   true,
+			wantStatus:  http.StatusNoContent,
+			wantOrigin:  "https://tools.example.com",
+			wantHeaders: "Authorization, Content-Type",
+			wantMaxAge:  "600",
+		},
+		{
+			name:       "rejected preflight",
+			method:     http.MethodOptions,
+			origin:     "https://evil.example.com",
+			preflight:  true,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passedOn := false
+			handler := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				passedOn = true
+				next.ServeHTTP(w, r)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/v1/races", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if passedOn != tt.wantPassedOn {
+				t.Errorf("passed on = %v, want %v", passedOn, tt.wantPassedOn)
+			}
+
+			for header, want := range map[string]string{
+				"Access-Control-Allow-Origin":   tt.wantOrigin,
+				"Access-Control-Allow-Headers":  tt.wantHeaders,
+				"Access-Control-Expose-Headers": tt.wantExposed,
+				"Access-Control-Max-Age":        tt.wantMaxAge,
+			} {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package docs
+
+import (
+	"embed"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+const (
+	// SpecPath serves the OpenAPI spec.
+	SpecPath = "/openapi.json"
+	// UIPath serves Swagger UI, rendering the spec.
+	UIPath = "/docs"
+)
+
+// pageContentSecurityPolicy lets the Swagger UI page load Swagger UI and fetch
+// the spec from the API itself, while the API's own responses allow nothing.
+// Swagger UI sets inline styles, so those are allowed too.
+const pageContentSecurityPolicy = "default-src 'none'; script-src 'self'; " +
+	"style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+
+//go:embed index.html init.js swagger-ui/swagger-ui-bundle.js swagger-ui/swagger-ui.css
+var assets embed.FS
+
+// asset is an embedded file served under UIPath.
+type asset struct {
+	file        string
+	path        string
+	contentType string
+}
+
+var uiAssets = []asset{
+	// Kept out of the page, so its policy needn't allow inline scripts.
+	{file: "init.js", path: UIPath + "/init.js", contentType: "application/javascript"},
+	{file: "swagger-ui/swagger-ui-bundle.js", path: UIPath + "/swagger-ui-bundle.js", contentType: "application/javascript"},
+	{file: "swagger-ui/swagger-ui.css", path: UIPath + "/swagger-ui.css", contentType: "text/css; charset=utf-8"},
+}
+
+// Register serves spec at SpecPath and a Swagger UI page for it at UIPath.
+// Swagger UI is vendored and embedded, so the page loads nothing from
+// elsewhere.
+func Register(mux *runtime.ServeMux, spec []byte) error {
+	page, err := assets.ReadFile("index.html")
+	if err != nil {
+		return err
+	}
+
+	if err := mux.HandlePath(http.MethodGet, SpecPath, serve("application/json", spec, "")); err != nil {
+		return err
+	}
+
+	for _, a := range uiAssets {
+		body, err := assets.ReadFile(a.file)
+		if err != nil {
+			return err
+		}
+
+		if err := mux.HandlePath(http.MethodGet, a.path, serve(a.contentType, body, "")); err != nil {
+			return err
+		}
+	}
+
+	return mux.HandlePath(http.MethodGet, UIPath, serve("text/html; charset=utf-8", page, pageContentSecurityPolicy))
+}
+
+func serve(contentType string, body []byte, contentSecurityPolicy string) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.Header().Set("Content-Type", contentType)
+		if contentSecurityPolicy != "" {
+			w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+		}
+		_, _ = w.Write(body)
+	}
+}
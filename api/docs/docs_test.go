@@ -0,0 +1,41 @@
+package docs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRegister(t *testing.T) {
+	mux := runtime.NewServeMux()
+	if err := Register(mux, []byte(`{"swagger":"2.0"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path            string
+		wantContentType string
+		wantBody        string
+	}{
+		{path: SpecPath, wantContentType: "application/json", wantBody: `{"swagger":"2.0"}`},
+		{path: UIPath, wantContentType: "text/html; charset=utf-8", wantBody: `<script src="/docs/init.js">`},
+		{path: UIPath + "/init.js", wantContentType: "application/javascript", wantBody: `url: "/openapi.json"`},
+		{path: UIPath + "/swagger-ui-bundle.js", wantContentType: "application/javascript", wantBody: "SwaggerUIBundle"},
+		{path: UIPath + "/swagger-ui.css", wantContentType: "text/css; charset=utf-8", wantBody: ".swagger-ui"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.wantContentType || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("GET %s = %d %q, want %q containing %q", tt.path, w.Code, w.Header().Get("Content-Type"), tt.wantContentType, tt.wantBody)
+		}
+		if tt.path == UIPath && !strings.Contains(w.Header().Get("Content-Security-Policy"), "script-src 'self';") {
+			t.Errorf("GET %s Content-Security-Policy = %q, want scripts from the API only", tt.path, w.Header().Get("Content-Security-Policy"))
+		}
+	}
+}
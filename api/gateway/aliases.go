@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"net/http"
+)
+
+// RacesPath is the RESTful route listing races from query parameters.
+const RacesPath = "/v1/races"
+
+// RaceQueryAliases maps the query parameters accepted by GET /v1/races onto
+// the ListRacesRequest fields they populate, which the gateway otherwise only
+// accepts by their full path (e.g. filter.meeting_ids).
+var RaceQueryAliases = map[string]string{
+	"meeting_ids":                "filter.meeting_ids",
+	"visible":                    "filter.visible",
+	"advertised_start_time_from": "filter.advertised_start_time_from",
+}
+
+// QueryAliases rewrites aliased query parameters on GET requests to path into
+// the field paths the gateway mux expects. Parameters already given by their
+// full path are kept, with aliased values appended.
+func QueryAliases(path string, aliases map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+
+		var rewritten bool
+		for alias, field := range aliases {
+			values, ok := query[alias]
+			if !ok {
+				continue
+			}
+
+			query[field] = append(query[field], values...)
+			delete(query, alias)
+			rewritten = true
+		}
+
+		if rewritten {
+			u := *r.URL
+			u.RawQuery = query.Encode()
+
+			r = r.Clone(r.Context())
+			r.URL = &u
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	racinggw "git.neds.sh/matty/entain/proto/gateway/racing"
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// recordingRacingServer records the last ListRaces request it served.
+type recordingRacingServer struct {
+	racing.UnimplementedRacingServer
+	got *racing.ListRacesRequest
+}
+
+func (s *recordingRacingServer) ListRaces(_ context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
+	s.got = in
+	return &racing.ListRacesResponse{NextPageToken: "next"}, nil
+}
+
+func TestListRacesRoutes(t *testing.T) {
+	visible := true
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		want       *racing.ListRacesRequest
+	}{
+		{
+			name:       "get with aliases",
+			method:     http.MethodGet,
+			target:     "/v1/races?meeting_ids=1&meeting_ids=2&visible=true&order_by=advertised_start_time%20desc&page_size=10&page_token=abc",
+			wantStatus: http.StatusOK,
+			want: &racing.ListRacesRequest{
+				Filter:    &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 2}, Visible: &visible},
+				OrderBy:   "advertised_start_time desc",
+				PageSize:  10,
+				PageToken: "abc",
+			},
+		},
+		{
+			name:       "get from a start time",
+			method:     http.MethodGet,
+			target:     "/v1/races?advertised_start_time_from=2021-03-01T10:00:00Z",
+			wantStatus: http.StatusOK,
+			want: &racing.ListRacesRequest{
+				Filter: &racing.ListRacesRequestFilter{AdvertisedStartTimeFrom: timestamppb.New(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC))},
+			},
+		},
+		{
+			name:       "get with field paths",
+			method:     http.MethodGet,
+			target:     "/v1/races?filter.meeting_ids=3",
+			wantStatus: http.StatusOK,
+			want:       &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{3}}},
+		},
+		{
+			name:       "get without parameters",
+			method:     http.MethodGet,
+			target:     "/v1/races",
+			wantStatus: http.StatusOK,
+			want:       &racing.ListRacesRequest{},
+		},
+		{
+			name:       "get with invalid parameter",
+			method:     http.MethodGet,
+			target:     "/v1/races?page_size=lots",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "post still supported",
+			method:     http.MethodPost,
+			target:     "/v1/list-races",
+			body:       `{"filter":{"meeting_ids":[4]},"page_size":5}`,
+			wantStatus: http.StatusOK,
+			want:       &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{4}}, PageSize: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &recordingRacingServer{}
+
+			mux := runtime.NewServeMux()
+			if err := racinggw.RegisterRacingHandlerServer(context.Background(), mux, server); err != nil {
+				t.Fatal(err)
+			}
+			handler := QueryAliases(RacesPath, RaceQueryAliases, mux)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+
+			if !proto.Equal(server.got, tt.want) {
+				t.Errorf("ListRaces() request = %v, want %v", server.got, tt.want)
+			}
+			if !strings.Contains(w.Body.String(), `"nextPageToken":"next"`) {
+				t.Errorf("body = %s, want the next page token", w.Body)
+			}
+		})
+	}
+}
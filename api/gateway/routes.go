@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+
+	"git.neds.sh/matty/entain/proto"
+)
+
+// Routes maps the gateway's RPCs back to the route patterns they're bound to,
+// e.g. GET /v1/races/{id}, so requests can be named by route rather than by
+// path, which would name every race separately.
+type Routes struct {
+	// patterns is keyed by HTTP method then full RPC method name, e.g.
+	// /racing.Racing/GetRace. An RPC has at most one binding per HTTP method.
+	patterns map[string]map[string]string
+}
+
+// ParseRoutes reads the HTTP bindings from a gRPC API configuration, such as
+// proto.GatewayConfig.
+func ParseRoutes(config []byte) (*Routes, error) {
+	rules, err := proto.ParseHTTPRules(config)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := &Routes{patterns: make(map[string]map[string]string)}
+
+	for _, rule := range rules {
+		i := strings.LastIndex(rule.Selector, ".")
+		if i < 0 {
+			return nil, fmt.Errorf("gateway config: invalid selector %q", rule.Selector)
+		}
+		rpcMethod := "/" + rule.Selector[:i] + "/" + rule.Selector[i+1:]
+
+		for _, binding := range rule.Bindings() {
+			method, pattern := binding.Pattern()
+			if pattern == "" {
+				continue
+			}
+			if routes.patterns[method] == nil {
+				routes.patterns[method] = make(map[string]string)
+			}
+			routes.patterns[method][rpcMethod] = pattern
+		}
+	}
+
+	return routes, nil
+}
+
+// Name returns the route a request to rpcMethod was matched by, e.g.
+// "GET /v1/races/{id}". Handlers registered directly on the mux, which name
+// their RPC after their own fixed path, are named by that path.
+func (rs *Routes) Name(httpMethod, rpcMethod string) string {
+	if pattern, ok := rs.patterns[httpMethod][rpcMethod]; ok {
+		return httpMethod + " " + pattern
+	}
+
+	return httpMethod + " " + rpcMethod
+}
+
+// NameSpan renames the request's span after the route the gateway mux matched.
+// It is intended for use with runtime.WithMetadata, and adds no metadata itself.
+func (rs *Routes) NameSpan(ctx context.Context, r *http.Request) metadata.MD {
+	if rpcMethod, ok := runtime.RPCMethod(ctx); ok {
+		trace.SpanFromContext(r.Context()).SetName(rs.Name(r.Method, rpcMethod))
+	}
+
+	return nil
+}
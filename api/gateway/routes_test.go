@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"git.neds.sh/matty/entain/proto"
+	racinggw "git.neds.sh/matty/entain/proto/gateway/racing"
+)
+
+func TestRoutes_NameSpan(t *testing.T) {
+	routes, err := ParseRoutes(proto.GatewayConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		target string
+		want   string
+	}{
+		{method: http.MethodGet, target: "/v1/races/7", want: "GET /v1/races/{id}"},
+		{method: http.MethodGet, target: "/v1/races/8", want: "GET /v1/races/{id}"},
+		{method: http.MethodGet, target: "/v1/races", want: "GET /v1/races"},
+		{method: http.MethodPost, target: "/v1/list-races", want: "POST /v1/list-races"},
+		{method: http.MethodPut, target: "/v1/races/7", want: "PUT /v1/races/{race.id}"},
+		{method: http.MethodGet, target: "/v1/unknown/7", want: "HTTP GET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+			mux := runtime.NewServeMux(runtime.WithMetadata(routes.NameSpan))
+			if err := racinggw.RegisterRacingHandlerServer(context.Background(), mux, &recordingRacingServer{}); err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			ctx, span := tracer.Start(r.Context(), "HTTP "+tt.method)
+			mux.ServeHTTP(httptest.NewRecorder(), r.WithContext(ctx))
+			span.End()
+
+			if got := recorder.Ended()[0].Name(); got != tt.want {
+				t.Errorf("span name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutes_Name_unboundRPC(t *testing.T) {
+	routes, err := ParseRoutes(proto.GatewayConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := routes.Name(http.MethodGet, "/v1/upcoming"), "GET /v1/upcoming"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package grpcclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errOpen fails calls while the breaker is open.
+var errOpen = status.Error(codes.Unavailable, "backend unavailable: circuit breaker open")
+
+// Breaker is a circuit breaker for calls to one backend. After enough calls
+// in a row fail with Unavailable it opens, failing calls immediately rather
+// than leaving them to wait on a backend that's down. Once the cooldown has
+// passed a single call is let through to probe the backend, closing the
+// breaker if it succeeds and reopening it if not.
+type Breaker struct {
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	consecutive int
+	openUntil   time.Time
+	probing     bool
+}
+
+// NewBreaker creates a breaker opening after failures calls in a row fail
+// with Unavailable, for cooldown at a time.
+func NewBreaker(failures int, cooldown time.Duration) *Breaker {
+	return &Breaker{failures: failures, cooldown: cooldown, now: time.Now}
+}
+
+// UnaryClientInterceptor guards every unary call with the breaker.
+func (b *Breaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := b.allow(); err != nil {
+			return err
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.record(err)
+
+		return err
+	}
+}
+
+// allow returns errOpen if a call may not be made now.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.openUntil.IsZero():
+		return nil
+	case b.probing, b.now().Before(b.openUntil):
+		return errOpen
+	}
+
+	b.probing = true
+	return nil
+}
+
+// record counts the outcome of a call. Any answer other than Unavailable
+// shows the backend is up, even if it's an error, while calls the caller
+// cancelled show nothing.
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	switch status.Code(err) {
+	case codes.Canceled:
+		return
+	case codes.Unavailable:
+	default:
+		b.consecutive = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.consecutive++
+	if b.consecutive >= b.failures {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
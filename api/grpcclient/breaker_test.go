@@ -0,0 +1,60 @@
+package grpcclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	breaker := NewBreaker(3, 10*time.Second)
+	breaker.now = func() time.Time { return now }
+
+	interceptor := breaker.UnaryClientInterceptor()
+
+	// call makes a call the backend answers with code, reporting whether it
+	// reached the backend.
+	call := func(code codes.Code) bool {
+		invoked := false
+		invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			invoked = true
+			return status.Error(code, "")
+		}
+
+		_ = interceptor(context.Background(), "/racing.Racing/ListRaces", nil, nil, nil, invoker)
+		return invoked
+	}
+
+	steps := []struct {
+		name        string
+		advance     time.Duration
+		code        codes.Code
+		wantInvoked bool
+	}{
+		{name: "unavailable", code: codes.Unavailable, wantInvoked: true},
+		{name: "unavailable", code: codes.Unavailable, wantInvoked: true},
+		{name: "not found resets", code: codes.NotFound, wantInvoked: true},
+		{name: "unavailable", code: codes.Unavailable, wantInvoked: true},
+		{name: "unavailable", code: codes.Unavailable, wantInvoked: true},
+		{name: "unavailable opens", code: codes.Unavailable, wantInvoked: true},
+		{name: "open", code: codes.OK, wantInvoked: false},
+		{name: "still open", advance: 9 * time.Second, code: codes.OK, wantInvoked: false},
+		{name: "failed probe reopens", advance: time.Second, code: codes.Unavailable, wantInvoked: true},
+		{name: "reopened", code: codes.OK, wantInvoked: false},
+		{name: "cancelled probe", advance: 10 * time.Second, code: codes.Canceled, wantInvoked: true},
+		{name: "probe closes", code: codes.OK, wantInvoked: true},
+		{name: "closed", code: codes.OK, wantInvoked: true},
+	}
+
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		if invoked := call(step.code); invoked != step.wantInvoked {
+			t.Fatalf("step %d (%s): invoked = %v, want %v", i, step.name, invoked, step.wantInvoked)
+		}
+	}
+}
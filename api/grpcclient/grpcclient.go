@@ -0,0 +1,202 @@
+// Package grpcclient dials backend gRPC services resiliently: balancing calls
+// across replicas, retrying idempotent calls that fail transiently, keeping
+// idle connections alive, and failing fast while a backend is down.
+package grpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// staticScheme is the resolver scheme of endpoints given as a list of addresses.
+const staticScheme = "static"
+
+// Options configures a connection to a backend.
+type Options struct {
+	// Endpoint locates the backend's replicas: a host:port, a gRPC target
+	// such as dns:///racing:9000 (re-resolved as replicas come and go), or a
+	// comma separated list of host:ports.
+	Endpoint string
+
+	// RetryMethods lists the full names of idempotent methods, e.g.
+	// "/racing.Racing/ListRaces", which are retried when they fail with
+	// Unavailable.
+	RetryMethods []string
+	// RetryAttempts is the most attempts made at a call, including the first.
+	// Below 2 disables retries.
+	RetryAttempts int
+
+	// KeepaliveTime is how long a connection may be idle before it's pinged,
+	// to detect dead backends and keep proxies from dropping it. Zero
+	// disables keepalives.
+	KeepaliveTime time.Duration
+	// KeepaliveTimeout is how long to wait for a ping's ack before closing the
+	// connection.
+	KeepaliveTimeout time.Duration
+
+	// BreakerFailures is how many calls in a row must fail with Unavailable to
+	// open the circuit breaker. Zero disables it.
+	BreakerFailures int
+	// BreakerCooldown is how long the breaker stays open before letting a
+	// call through to probe the backend.
+	BreakerCooldown time.Duration
+}
+
+// Dial connects to a backend, with dialOpts for anything else such as
+// credentials and interceptors.
+func Dial(ctx context.Context, opts Options, dialOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	target, err := opts.target(&dialOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceConfig, err := ServiceConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	dialOpts = append(dialOpts, grpc.WithDefaultServiceConfig(serviceConfig))
+
+	if opts.KeepaliveTime > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                opts.KeepaliveTime,
+			Timeout:             opts.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	if opts.BreakerFailures > 0 {
+		breaker := NewBreaker(opts.BreakerFailures, opts.BreakerCooldown)
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(breaker.UnaryClientInterceptor()))
+	}
+
+	return grpc.DialContext(ctx, target, dialOpts...)
+}
+
+// target returns the gRPC target of the endpoint, adding a resolver to
+// dialOpts for lists of addresses.
+func (o Options) target(dialOpts *[]grpc.DialOption) (string, error) {
+	if err := ValidateEndpoint(o.Endpoint); err != nil {
+		return "", err
+	}
+
+	addrs := strings.Split(o.Endpoint, ",")
+	if strings.Contains(o.Endpoint, "://") || len(addrs) == 1 {
+		return o.Endpoint, nil
+	}
+
+	state := resolver.State{}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: strings.TrimSpace(addr)})
+	}
+
+	r := manual.NewBuilderWithScheme(staticScheme)
+	r.InitialState(state)
+	*dialOpts = append(*dialOpts, grpc.WithResolvers(r))
+
+	return staticScheme + ":///" + strings.TrimSpace(addrs[0]), nil
+}
+
+// ValidateEndpoint checks endpoint is a host:port, a gRPC target with a known
+// scheme, or a list of host:ports.
+func ValidateEndpoint(endpoint string) error {
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		if resolver.Get(endpoint[:i]) == nil {
+			return fmt.Errorf("unknown target scheme %q", endpoint[:i])
+		}
+		return nil
+	}
+
+	for _, addr := range strings.Split(endpoint, ",") {
+		if _, _, err := net.SplitHostPort(strings.TrimSpace(addr)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Host returns the host of the endpoint's first address, e.g. for verifying
+// the backend's certificate.
+func Host(endpoint string) (string, error) {
+	addr := strings.TrimSpace(strings.Split(endpoint, ",")[0])
+	if i := strings.Index(addr, "://"); i >= 0 {
+		// Skip the scheme and authority, e.g. dns://8.8.8.8/racing:9000.
+		addr = addr[i+len("://"):]
+		addr = addr[strings.Index(addr, "/")+1:]
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	return host, err
+}
+
+// serviceConfig is the subset of the gRPC service config used, documented at
+// https://github.com/grpc/grpc/blob/master/doc/service_config.md.
+type serviceConfig struct {
+	LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig"`
+	MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+	RetryThrottling     *retryThrottling      `json:"retryThrottling,omitempty"`
+}
+
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	RetryPolicy retryPolicy  `json:"retryPolicy"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+type retryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+type retryThrottling struct {
+	MaxTokens  int     `json:"maxTokens"`
+	TokenRatio float64 `json:"tokenRatio"`
+}
+
+// ServiceConfig returns the service config for connections made with opts:
+// round robin balancing across the backend's replicas, and retries of the
+// retry methods. Retries are throttled once many calls are failing, so they
+// don't pile onto a struggling backend.
+func ServiceConfig(opts Options) (string, error) {
+	config := serviceConfig{LoadBalancingConfig: []map[string]struct{}{{"round_robin": {}}}}
+
+	if opts.RetryAttempts >= 2 && len(opts.RetryMethods) != 0 {
+		mc := methodConfig{RetryPolicy: retryPolicy{
+			MaxAttempts:          opts.RetryAttempts,
+			InitialBackoff:       "0.1s",
+			MaxBackoff:           "1s",
+			BackoffMultiplier:    2,
+			RetryableStatusCodes: []string{"UNAVAILABLE"},
+		}}
+
+		for _, method := range opts.RetryMethods {
+			parts := strings.Split(strings.TrimPrefix(method, "/"), "/")
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return "", fmt.Errorf("retry method %q: want /<service>/<method>", method)
+			}
+			mc.Name = append(mc.Name, methodName{Service: parts[0], Method: parts[1]})
+		}
+
+		config.MethodConfig = []methodConfig{mc}
+		config.RetryThrottling = &retryThrottling{MaxTokens: 10, TokenRatio: 0.1}
+	}
+
+	b, err := json.Marshal(config)
+	return string(b), err
+}
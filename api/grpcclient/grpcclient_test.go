@@ -0,0 +1,152 @@
+package grpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "localhost:9000"},
+		{in: "dns:///racing:9000"},
+		{in: "racing-1:9000, racing-2:9000"},
+		{in: "racing", wantErr: true},
+		{in: "racing-1:9000,racing-2", wantErr: true},
+		{in: "nope:///racing:9000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateEndpoint(tt.in); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateEndpoint(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"localhost:9000":                  "localhost",
+		"dns:///racing:9000":              "racing",
+		"dns://8.8.8.8/racing:9000":       "racing",
+		"racing-1:9000, racing-2:9000":    "racing-1",
+		"passthrough:///10.0.0.1:9000":    "10.0.0.1",
+		"passthrough:///[2001:db8::1]:90": "2001:db8::1",
+	} {
+		if got, err := Host(endpoint); err != nil || got != want {
+			t.Errorf("Host(%q) = %q, %v, want %q", endpoint, got, err, want)
+		}
+	}
+}
+
+func TestServiceConfig(t *testing.T) {
+	config, err := ServiceConfig(Options{RetryMethods: []string{"/racing.Racing/ListRaces"}, RetryAttempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed serviceConfig
+	if err := json.Unmarshal([]byte(config), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.MethodConfig) != 1 || parsed.MethodConfig[0].Name[0] != (methodName{Service: "racing.Racing", Method: "ListRaces"}) {
+		t.Errorf("ServiceConfig() = %s, want a retry policy for racing.Racing/ListRaces", config)
+	}
+
+	if config, _ := ServiceConfig(Options{RetryMethods: []string{"/racing.Racing/ListRaces"}, RetryAttempts: 1}); config != `{"loadBalancingConfig":[{"round_robin":{}}]}` {
+		t.Errorf("ServiceConfig() without retries = %s, want round robin alone", config)
+	}
+
+	if _, err := ServiceConfig(Options{RetryMethods: []string{"ListRaces"}, RetryAttempts: 3}); err == nil {
+		t.Errorf("ServiceConfig() with an unqualified method succeeded, want error")
+	}
+}
+
+// calls counts the calls made to every flakyServer.
+type calls struct {
+	mu sync.Mutex
+	n  int
+}
+
+// flakyServer fails every third call made to any replica with Unavailable, and
+// counts the calls it answers successfully.
+type flakyServer struct {
+	racing.UnimplementedRacingServer
+
+	calls    *calls
+	answered int
+}
+
+func (s *flakyServer) ListRaces(context.Context, *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
+	s.calls.mu.Lock()
+	defer s.calls.mu.Unlock()
+
+	s.calls.n++
+	if s.calls.n%3 == 1 {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+
+	s.answered++
+	return &racing.ListRacesResponse{}, nil
+}
+
+func TestDial(t *testing.T) {
+	shared := &calls{}
+	replicas := map[string]*flakyServer{"racing-1:9000": {calls: shared}, "racing-2:9000": {calls: shared}}
+	listeners := make(map[string]*bufconn.Listener)
+
+	for addr, backend := range replicas {
+		listener := bufconn.Listen(1 << 20)
+		listeners[addr] = listener
+
+		server := grpc.NewServer()
+		racing.RegisterRacingServer(server, backend)
+		go func() { _ = server.Serve(listener) }()
+		defer server.Stop()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := Dial(ctx, Options{
+		Endpoint:        "racing-1:9000,racing-2:9000",
+		RetryMethods:    []string{"/racing.Racing/ListRaces"},
+		RetryAttempts:   2,
+		KeepaliveTime:   time.Minute,
+		BreakerFailures: 5,
+		BreakerCooldown: time.Second,
+	},
+		grpc.WithContextDialer(func(_ context.Context, addr string) (net.Conn, error) { return listeners[addr].Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := racing.NewRacingClient(conn)
+	for i := 0; i < 10; i++ {
+		if _, err := client.ListRaces(ctx, &racing.ListRacesRequest{}); err != nil {
+			t.Fatalf("ListRaces() call %d error = %v, want it retried", i, err)
+		}
+	}
+
+	for addr, backend := range replicas {
+		if backend.answered == 0 {
+			t.Errorf("replica %s answered no calls, want calls balanced across replicas", addr)
+		}
+	}
+}
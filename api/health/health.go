@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Probes serves the gateway's liveness and readiness endpoints.
+type Probes struct {
+	client   healthpb.HealthClient
+	timeout  time.Duration
+	services []string
+	draining int32
+}
+
+// response is the JSON body returned by the probe endpoints.
+type response struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewProbes creates probes whose readiness depends on each of the given
+// upstream gRPC services being SERVING, according to the health service on conn.
+func NewProbes(conn *grpc.ClientConn, timeout time.Duration, services ...string) *Probes {
+	return &Probes{
+		client:   healthpb.NewHealthClient(conn),
+		timeout:  timeout,
+		services: services,
+	}
+}
+
+// SetDraining makes readiness fail from now on, so the gateway is taken out
+// of rotation while it shuts down.
+func (p *Probes) SetDraining() {
+	atomic.StoreInt32(&p.draining, 1)
+}
+
+// Liveness reports whether the process is up and able to serve HTTP requests.
+func (p *Probes) Liveness(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+	write(w, http.StatusOK, response{Status: "ok"})
+}
+
+// Readiness reports whether the gateway should receive traffic: it isn't
+// shutting down, and every upstream service is reachable and SERVING.
+func (p *Probes) Readiness(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	if atomic.LoadInt32(&p.draining) == 1 {
+		write(w, http.StatusServiceUnavailable, response{Status: "unavailable", Error: "shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+	defer cancel()
+
+	for _, service := range p.services {
+		resp, err := p.client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			write(w, http.StatusServiceUnavailable, response{Status: "unavailable", Error: err.Error()})
+			return
+		}
+
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			write(w, http.StatusServiceUnavailable, response{Status: "unavailable", Error: service + " is " + resp.Status.String()})
+			return
+		}
+	}
+
+	write(w, http.StatusOK, response{Status: "ok"})
+}
+
+func write(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
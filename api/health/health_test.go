@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const service = "racing.Racing"
+
+// newProbes returns probes checking service on a health server, which the
+// caller sets statuses on.
+func newProbes(t *testing.T) (*Probes, *health.Server) {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	healthServer := health.NewServer()
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewProbes(conn, time.Second, service), healthServer
+}
+
+func TestProbes_Readiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     *healthpb.HealthCheckResponse_ServingStatus
+		wantStatus int
+		wantError  string
+	}{
+		{name: "serving", status: healthpb.HealthCheckResponse_SERVING.Enum(), wantStatus: http.StatusOK},
+		{name: "not serving", status: healthpb.HealthCheckResponse_NOT_SERVING.Enum(), wantStatus: http.StatusServiceUnavailable, wantError: service + " is NOT_SERVING"},
+		{name: "unknown service", wantStatus: http.StatusServiceUnavailable, wantError: "rpc error: code = NotFound desc = unknown service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes, healthServer := newProbes(t)
+			if tt.status != nil {
+				healthServer.SetServingStatus(service, *tt.status)
+			}
+
+			status, resp := probe(probes.Readiness)
+			if status != tt.wantStatus || resp.Error != tt.wantError {
+				t.Errorf("Readiness() = %d %+v, want %d with error %q", status, resp, tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestProbes_Liveness(t *testing.T) {
+	// Liveness doesn't depend on the upstream services.
+	probes, healthServer := newProbes(t)
+	healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	if status, resp := probe(probes.Liveness); status != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("Liveness() = %d %+v, want 200 ok", status, resp)
+	}
+}
+
+// probe calls a probe endpoint, returning its status code and decoded body.
+func probe(handler func(http.ResponseWriter, *http.Request, map[string]string)) (int, response) {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil), nil)
+
+	var resp response
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+
+	return rec.Code, resp
+}
+
+func TestProbes_SetDraining(t *testing.T) {
+	probes, healthServer := newProbes(t)
+	healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+
+	probes.SetDraining()
+
+	if status, resp := probe(probes.Readiness); status != http.StatusServiceUnavailable || resp.Error != "shutting down" {
+		t.Errorf("Readiness() while draining = %d %+v, want 503 shutting down", status, resp)
+	}
+	if status, _ := probe(probes.Liveness); status != http.StatusOK {
+		t.Errorf("Liveness() while draining = %d, want 200", status)
+	}
+}
@@ -0,0 +1,53 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// startTimeField is the JSON name of a race's advertised start time.
+const startTimeField = "advertisedStartTime"
+
+// RaceMaxAge returns a Freshness func allowing responses to be cached for up
+// to limit, but never past the next advertised start time in the response,
+// since jumping changes which races are open and next to jump.
+func RaceMaxAge(limit time.Duration) func(body []byte, now time.Time) time.Duration {
+	return func(body []byte, now time.Time) time.Duration {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return 0
+		}
+
+		maxAge := limit
+
+		walkStartTimes(doc, func(start time.Time) {
+			if until := start.Sub(now); until > 0 && until < maxAge {
+				maxAge = until
+			}
+		})
+
+		return maxAge.Truncate(time.Second)
+	}
+}
+
+// walkStartTimes calls fn with every advertised start time in the decoded JSON document.
+func walkStartTimes(doc interface{}, fn func(time.Time)) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && key == startTimeField {
+				if start, err := time.Parse(time.RFC3339, s); err == nil {
+					fn(start)
+				}
+
+				continue
+			}
+
+			walkStartTimes(value, fn)
+		}
+	case []interface{}:
+		for _, value := range v {
+			walkStartTimes(value, fn)
+		}
+	}
+}
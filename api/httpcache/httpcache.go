@@ -0,0 +1,280 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCachedRequestBody bounds the request bodies read to build cache keys.
+const maxCachedRequestBody = 64 << 10
+
+// Options configures a Cacher.
+type Options struct {
+	// Routes are the routes whose responses are cacheable, named as in the
+	// gateway config, e.g. "GET /v1/races/{id}". A {variable} segment matches
+	// any one non-empty path segment.
+	Routes []string
+	// Freshness returns how long a response body may be cached for.
+	Freshness func(body []byte, now time.Time) time.Duration
+	// Store, when set, serves repeated anonymous requests from memory.
+	Store *Store
+}
+
+// Cacher adds validators and freshness to cacheable responses.
+type Cacher struct {
+	opts   Options
+	routes []route
+	now    func() time.Time
+}
+
+// route is a parsed route, matched segment by segment.
+type route struct {
+	method   string
+	segments []string
+}
+
+// NewCacher creates a new cacher.
+func NewCacher(opts Options) *Cacher {
+	routes := make([]route, 0, len(opts.Routes))
+	for _, name := range opts.Routes {
+		method, path := "", name
+		if i := strings.IndexByte(name, ' '); i >= 0 {
+			method, path = name[:i], name[i+1:]
+		}
+
+		routes = append(routes, route{method: method, segments: strings.Split(path, "/")})
+	}
+
+	return &Cacher{opts: opts, routes: routes, now: time.Now}
+}
+
+// cacheable reports whether r matches one of the cacheable routes.
+func (c *Cacher) cacheable(r *http.Request) bool {
+	segments := strings.Split(r.URL.Path, "/")
+
+	for _, route := range c.routes {
+		if route.matches(r.Method, segments) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rt route) matches(method string, segments []string) bool {
+	if method != rt.method || len(segments) != len(rt.segments) {
+		return false
+	}
+
+	for i, segment := range rt.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return false
+			}
+
+			continue
+		}
+
+		if segments[i] != segment {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Middleware gives successful responses on cacheable routes a strong ETag and a
+// Cache-Control max-age, answers matching If-None-Match requests with 304 Not
+// Modified, and, when a store is configured, replays stored responses to
+// anonymous requests for the same normalised query. Responses to requests
+// carrying credentials may differ per caller, so they're marked private and
+// never stored.
+func (c *Cacher) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.cacheable(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		anonymous := r.Header.Get("Authorization") == ""
+
+		var key string
+		if c.opts.Store != nil && anonymous {
+			var ok bool
+			if key, ok = requestKey(r); ok {
+				if resp, ok := c.opts.Store.get(key); ok {
+					w.Header().Set("X-Cache", "HIT")
+					// Stored responses age, so only the rest of their lifetime is left.
+					c.write(w, r, resp, resp.expires.Sub(c.now()).Truncate(time.Second), anonymous)
+
+					return
+				}
+
+				w.Header().Set("X-Cache", "MISS")
+			}
+		}
+
+		rec := &recorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.status != http.StatusOK {
+			rec.flush(w)
+			return
+		}
+
+		resp := &response{
+			header: rec.header,
+			body:   rec.body.Bytes(),
+			etag:   etag(rec.body.Bytes()),
+			maxAge: c.opts.Freshness(rec.body.Bytes(), c.now()),
+		}
+
+		if key != "" && resp.maxAge > 0 {
+			c.opts.Store.add(key, resp)
+		}
+
+		c.write(w, r, resp, resp.maxAge, anonymous)
+	})
+}
+
+// write sends resp, or 304 Not Modified when the client already has it.
+func (c *Cacher) write(w http.ResponseWriter, r *http.Request, resp *response, maxAge time.Duration, anonymous bool) {
+	header := w.Header()
+	for name, values := range resp.header {
+		header[name] = append([]string(nil), values...)
+	}
+
+	header.Set("ETag", resp.etag)
+	header.Add("Vary", "Authorization")
+
+	visibility := "private"
+	if anonymous {
+		visibility = "public"
+	}
+
+	if maxAge > 0 {
+		header.Set("Cache-Control", visibility+", max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	} else {
+		header.Set("Cache-Control", visibility+", no-cache")
+	}
+
+	if matchesETag(r.Header.Get("If-None-Match"), resp.etag) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(resp.body)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp.body)
+}
+
+// requestKey normalises a request into a cache key, so equivalent queries
+// share an entry regardless of JSON formatting, key order or query parameter
+// order. It reports false for requests that can't be normalised.
+func requestKey(r *http.Request) (string, bool) {
+	query := r.URL.Query()
+	for _, values := range query {
+		sort.Strings(values)
+	}
+
+	key := r.Method + " " + r.URL.Path + "?" + query.Encode()
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return key, true
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCachedRequestBody+1))
+	// Put back what was read, so the request can still be served uncached.
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxCachedRequestBody {
+		return "", false
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return key, true
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", false
+	}
+
+	// Maps marshal with sorted keys.
+	normalised, err := json.Marshal(doc)
+	if err != nil {
+		return "", false
+	}
+
+	return key + " " + string(normalised), true
+}
+
+// etag returns a strong entity tag for body.
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+}
+
+// matchesETag reports whether an If-None-Match header matches etag, using the
+// weak comparison RFC 7232 requires for If-None-Match.
+func matchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readCloser reads from a replacement reader, closing the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// recorder buffers a response so it can be inspected before it's sent.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// flush sends the buffered response unchanged.
+func (r *recorder) flush(w http.ResponseWriter) {
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body.Bytes())
+}
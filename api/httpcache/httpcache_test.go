@@ -0,0 +1,181 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRaceMaxAge(t *testing.T) {
+	now := time.Date(2021, 3, 2, 19, 0, 0, 0, time.UTC)
+	freshness := RaceMaxAge(30 * time.Second)
+
+	tests := []struct {
+		name string
+		body string
+		want time.Duration
+	}{
+		{
+			name: "no races",
+			body: `{"races":[]}`,
+			want: 30 * time.Second,
+		},
+		{
+			name: "next race jumps soon",
+			body: `{"races":[{"advertisedStartTime":"2021-03-02T19:05:00Z"},{"advertisedStartTime":"2021-03-02T19:00:10Z"}]}`,
+			want: 10 * time.Second,
+		},
+		{
+			name: "past races are ignored",
+			body: `{"races":[{"advertisedStartTime":"2021-03-02T18:00:00Z"}]}`,
+			want: 30 * time.Second,
+		},
+		{
+			name: "invalid json",
+			body: `nope`,
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freshness([]byte(tt.body), now); got != tt.want {
+				t.Errorf("RaceMaxAge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacher_Middleware(t *testing.T) {
+	var calls int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.RawQuery, "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(`{"races":[]}`))
+	})
+
+	handler := NewCacher(Options{
+		Routes:    []string{"POST /v1/list-races"},
+		Freshness: RaceMaxAge(30 * time.Second),
+		Store:     NewStore(10),
+	}).Middleware(next)
+
+	do := func(target, body string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	first := do("/v1/list-races", `{"filter": {"visible": true, "meeting_ids": [1]}}`, nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first response = %d %v", first.Code, first.Header())
+	}
+	if got := first.Header().Get("Cache-Control"); got != "public, max-age=30" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+
+	// The same query, formatted differently, is served from the store.
+	second := do("/v1/list-races", `{"filter":{"meeting_ids":[1],"visible":true}}`, nil)
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != `{"races":[]}` || calls != 1 {
+		t.Errorf("second response = %v %q after %d calls, want a cache hit", second.Header(), second.Body, calls)
+	}
+
+	notModified := do("/v1/list-races", `{}`, http.Header{"If-None-Match": {`"other", ` + etag}})
+	if notModified.Code != http.StatusNotModified || notModified.Body.Len() != 0 {
+		t.Errorf("conditional response = %d %q, want an empty 304", notModified.Code, notModified.Body)
+	}
+
+	// Credentialed requests bypass the store and are private.
+	private := do("/v1/list-races", `{}`, http.Header{"Authorization": {"Bearer x"}})
+	if private.Header().Get("X-Cache") != "" || !strings.HasPrefix(private.Header().Get("Cache-Control"), "private") {
+		t.Errorf("credentialed response headers = %v", private.Header())
+	}
+
+	failed := do("/v1/list-races?fail", `{}`, nil)
+	if failed.Code != http.StatusInternalServerError || failed.Header().Get("ETag") != "" {
+		t.Errorf("failed response = %d %v, want passed through", failed.Code, failed.Header())
+	}
+
+	other := do("/v1/other", `{}`, nil)
+	if other.Header().Get("ETag") != "" {
+		t.Errorf("uncacheable path got an ETag")
+	}
+}
+
+func TestCacher_Middleware_routes(t *testing.T) {
+	body := `{"id":"42","advertisedStartTime":"2021-03-02T19:05:00Z"}`
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	})
+
+	handler := NewCacher(Options{
+		Routes:    []string{"GET /v1/races", "GET /v1/races/{id}"},
+		Freshness: RaceMaxAge(30 * time.Second),
+	}).Middleware(next)
+
+	tag := etag([]byte(body))
+
+	tests := []struct {
+		method    string
+		target    string
+		cacheable bool
+	}{
+		{method: http.MethodGet, target: "/v1/races", cacheable: true},
+		{method: http.MethodGet, target: "/v1/races/42", cacheable: true},
+		{method: http.MethodGet, target: "/v1/races/42?x=1", cacheable: true},
+		{method: http.MethodPut, target: "/v1/races/42"},
+		{method: http.MethodGet, target: "/v1/races/"},
+		{method: http.MethodGet, target: "/v1/races/42/stats"},
+		{method: http.MethodGet, target: "/v1/other/42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Header.Set("If-None-Match", tag)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if tt.cacheable && (w.Code != http.StatusNotModified || w.Header().Get("ETag") != tag) {
+				t.Errorf("response = %d with ETag %q, want 304 with %q", w.Code, w.Header().Get("ETag"), tag)
+			}
+			if !tt.cacheable && (w.Code != http.StatusOK || w.Header().Get("ETag") != "") {
+				t.Errorf("response = %d with ETag %q, want passed through", w.Code, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestStore_expiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewStore(1)
+	store.now = func() time.Time { return now }
+
+	store.add("a", &response{maxAge: time.Second})
+	if _, ok := store.get("a"); !ok {
+		t.Fatal("get() missed a fresh response")
+	}
+
+	store.add("b", &response{maxAge: time.Second})
+	if _, ok := store.get("a"); ok {
+		t.Error("get() returned an evicted response")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := store.get("b"); ok {
+		t.Error("get() returned an expired response")
+	}
+}
@@ -0,0 +1,88 @@
+package httpcache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// response is a complete, successful response ready to be replayed.
+type response struct {
+	header  http.Header
+	body    []byte
+	etag    string
+	maxAge  time.Duration
+	expires time.Time
+}
+
+// Store is an in-process LRU cache of responses, each kept until its max-age expires.
+type Store struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type storeEntry struct {
+	key  string
+	resp *response
+}
+
+// NewStore creates a new response store holding up to size responses.
+func NewStore(size int) *Store {
+	return &Store{
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns the unexpired response stored under key.
+func (s *Store) get(key string) (*response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*storeEntry)
+	if !s.now().Before(entry.resp.expires) {
+		s.lru.Remove(el)
+		delete(s.entries, key)
+
+		return nil, false
+	}
+
+	s.lru.MoveToFront(el)
+
+	return entry.resp, true
+}
+
+// add stores resp under key until it expires, evicting the least recently used response when full.
+func (s *Store) add(key string, resp *response) {
+	resp.expires = s.now().Add(resp.maxAge)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		el.Value.(*storeEntry).resp = resp
+		s.lru.MoveToFront(el)
+
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&storeEntry{key: key, resp: resp})
+
+	if s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*storeEntry).key)
+	}
+}
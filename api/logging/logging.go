@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"git.neds.sh/matty/entain/api/requestid"
+	"git.neds.sh/matty/entain/api/statusrecorder"
+)
+
+type loggerKey struct{}
+
+// NewLogger creates a JSON logger at the given level, e.g. "info" or "debug".
+func NewLogger(level string) (*logrus.Logger, error) {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(lvl)
+
+	return logger, nil
+}
+
+// FromContext returns the request-scoped logger stored in ctx, falling back to
+// the standard logger outside of a request.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// Middleware attaches a request-scoped logger, carrying the request ID given by
+// requestid.Middleware, to each request's context, and writes one access log
+// line once the request completes.
+func Middleware(logger *logrus.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		entry := logger.WithFields(logrus.Fields{
+			"request_id":  requestid.FromContext(r.Context()),
+			"http_method": r.Method,
+			"http_path":   r.URL.Path,
+		})
+		if spanCtx := trace.SpanContextFromContext(r.Context()); spanCtx.HasTraceID() {
+			entry = entry.WithField("trace_id", spanCtx.TraceID().String())
+		}
+
+		rec := statusrecorder.New(w)
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), loggerKey{}, entry)))
+
+		entry = entry.WithFields(logrus.Fields{
+			"http_status": rec.Status,
+			"latency_ms":  float64(time.Since(start).Microseconds()) / 1000,
+		})
+		if rec.Status >= http.StatusInternalServerError {
+			entry.Warn("request failed")
+		} else {
+			entry.Info("request completed")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"git.neds.sh/matty/entain/api/requestid"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantLevel logrus.Level
+	}{
+		{name: "ok", status: http.StatusOK, wantLevel: logrus.InfoLevel},
+		{name: "client error", status: http.StatusNotFound, wantLevel: logrus.InfoLevel},
+		{name: "server error", status: http.StatusBadGateway, wantLevel: logrus.WarnLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := logrustest.NewNullLogger()
+
+			var handlerRequestID interface{}
+			handler := requestid.Middleware(Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerRequestID = FromContext(r.Context()).Data["request_id"]
+				w.WriteHeader(tt.status)
+			})))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/races/1", nil))
+
+			if len(hook.Entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(hook.Entries))
+			}
+			entry := hook.LastEntry()
+
+			if entry.Level != tt.wantLevel {
+				t.Errorf("level = %v, want %v", entry.Level, tt.wantLevel)
+			}
+
+			requestID := rec.Header().Get(requestid.Header)
+			want := logrus.Fields{
+				"request_id":  requestID,
+				"http_method": http.MethodGet,
+				"http_path":   "/v1/races/1",
+				"http_status": tt.status,
+			}
+			for key, value := range want {
+				if entry.Data[key] != value {
+					t.Errorf("%s = %v, want %v", key, entry.Data[key], value)
+				}
+			}
+			if _, ok := entry.Data["latency_ms"].(float64); !ok {
+				t.Errorf("latency_ms = %v, want a number", entry.Data["latency_ms"])
+			}
+			if requestID == "" || handlerRequestID != requestID {
+				t.Errorf("handler's request_id = %v, want %q", handlerRequestID, requestID)
+			}
+		})
+	}
+}
@@ -2,44 +2,428 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
 	"flag"
-	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/bodylimit"
+	"git.neds.sh/matty/entain/api/compress"
+	"git.neds.sh/matty/entain/api/config"
+	"git.neds.sh/matty/entain/api/cors"
+	"git.neds.sh/matty/entain/api/docs"
+	"git.neds.sh/matty/entain/api/gateway"
+	"git.neds.sh/matty/entain/api/grpcclient"
+	"git.neds.sh/matty/entain/api/health"
+	"git.neds.sh/matty/entain/api/httpcache"
+	"git.neds.sh/matty/entain/api/logging"
+	"git.neds.sh/matty/entain/api/metrics"
+	"git.neds.sh/matty/entain/api/problem"
+	"git.neds.sh/matty/entain/api/ratelimit"
+	"git.neds.sh/matty/entain/api/requestid"
+	"git.neds.sh/matty/entain/api/security"
+	"git.neds.sh/matty/entain/api/upcoming"
+	"git.neds.sh/matty/entain/api/webrpc"
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+	"git.neds.sh/matty/entain/pkg/settings"
+	"git.neds.sh/matty/entain/pkg/tlsutil"
+	"git.neds.sh/matty/entain/pkg/tracing"
+	"git.neds.sh/matty/entain/proto"
+	racinggw "git.neds.sh/matty/entain/proto/gateway/racing"
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/proto/sports"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
-)
-
-var (
-	apiEndpoint  = flag.String("api-endpoint", "localhost:8000", "API endpoint")
-	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	flag.Parse()
+	cfg := config.Default()
+	printConfig, err := settings.LoadConfig(os.Args, config.EnvPrefix, cfg)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		logrus.Fatalf("failed loading config: %s", err)
+	}
+
+	if printConfig {
+		if err := settings.Print(os.Stdout, cfg); err != nil {
+			logrus.Fatalf("failed printing config: %s", err)
+		}
+		return
+	}
+
+	logger, err := logging.NewLogger(cfg.LogLevel)
+	if err != nil {
+		logrus.Fatalf("invalid log level: %s", err)
+	}
 
-	if err := run(); err != nil {
-		log.Printf("failed running api server: %s\n", err)
+	if err := run(cfg, logger); err != nil {
+		logger.WithError(err).Error("failed running api server")
 	}
 }
 
-func run() error {
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// metricsShutdownTimeout bounds closing the metrics server, once the main
+// server has drained.
+const metricsShutdownTimeout = 5 * time.Second
+
+func run(cfg *config.Config, logger *logrus.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, "api", cfg.TraceExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.WithError(err).Warn("failed flushing traces")
+		}
+	}()
+
+	routes, err := gateway.ParseRoutes(proto.GatewayConfig)
+	if err != nil {
+		return err
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithErrorHandler(problem.ErrorHandler),
+		runtime.WithMetadata(requestid.OutgoingMetadata),
+		runtime.WithMetadata(metrics.RecordRoute),
+		runtime.WithMetadata(routes.NameSpan),
+	)
+	racingConn, err := dial(ctx, cfg, cfg.GRPCEndpoint, cfg.GRPCServerName, retriedRacingMethods)
+	if err != nil {
+		return err
+	}
+	defer racingConn.Close()
+
+	if err := racinggw.RegisterRacingHandler(ctx, mux, racingConn); err != nil {
+		return err
+	}
+
+	probes := health.NewProbes(racingConn, cfg.ReadinessTimeout, racing.Racing_ServiceDesc.ServiceName)
+
+	if err := mux.HandlePath(http.MethodGet, "/healthz", probes.Liveness); err != nil {
+		return err
+	}
+	if err := mux.HandlePath(http.MethodGet, "/readyz", probes.Readiness); err != nil {
+		return err
+	}
+
+	upcomingSources := []upcoming.Source{upcoming.NewRacingSource(racing.NewRacingClient(racingConn))}
 
-	mux := runtime.NewServeMux()
-	if err := racing.RegisterRacingHandlerFromEndpoint(
-		ctx,
-		mux,
-		*grpcEndpoint,
-		[]grpc.DialOption{grpc.WithInsecure()},
-	); err != nil {
+	if cfg.SportsGRPCEndpoint != "" {
+		sportsConn, err := dial(ctx, cfg, cfg.SportsGRPCEndpoint, "", retriedSportsMethods)
+		if err != nil {
+			return err
+		}
+		defer sportsConn.Close()
+
+		upcomingSources = append(upcomingSources, upcoming.NewSportsSource(sports.NewSportsClient(sportsConn)))
+	}
+
+	if err := upcoming.Register(mux, cfg.UpcomingTimeout, upcomingSources...); err != nil {
+		return err
+	}
+
+	if err := docs.Register(mux, proto.OpenAPISpec); err != nil {
 		return err
 	}
 
-	log.Printf("API server listening on: %s\n", *apiEndpoint)
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+
+	metricsServer := &http.Server{Addr: cfg.MetricsEndpoint, Handler: metricsMux}
+
+	go func() {
+		logger.Infof("metrics server listening on: %s", cfg.MetricsEndpoint)
+
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Error("failed running metrics server")
+		}
+	}()
+
+	var responseStore *httpcache.Store
+	if cfg.ResponseCacheEnabled {
+		responseStore = httpcache.NewStore(cfg.ResponseCacheSize)
+	}
+
+	var handler http.Handler = httpcache.NewCacher(httpcache.Options{
+		Routes: []string{
+			"POST /v1/list-races",
+			"GET " + gateway.RacesPath,
+			"GET " + gateway.RacesPath + "/{id}",
+			"POST /v1/next-to-jump",
+			"POST /v1/race-stats",
+		},
+		Freshness: httpcache.RaceMaxAge(cfg.ResponseMaxAge),
+		Store:     responseStore,
+	}).Middleware(gateway.QueryAliases(gateway.RacesPath, gateway.RaceQueryAliases, mux))
+
+	if cfg.WebRPCEnabled {
+		handler = webrpc.NewProxy(mux, racingConn, racing.File_racing_racing_proto.Services().ByName("Racing")).Middleware(handler)
+	}
+
+	wrappers, err := middlewares(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	handler = chain(handler, wrappers...)
+
+	server := &http.Server{Addr: cfg.APIEndpoint, Handler: handler}
+
+	if cfg.TLSCertFile != "" {
+		keyPair, err := tlsutil.LoadKeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+
+		server.TLSConfig = tlsutil.ServerConfig(keyPair, nil)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"tls":      server.TLSConfig != nil,
+		"grpc_tls": cfg.GRPCTLS,
+	}).Infof("API server listening on: %s", cfg.APIEndpoint)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			// The certificate comes from TLSConfig, so it can be reloaded.
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Restore default signal handling, so a second signal kills the process
+	// rather than waiting out the drain.
+	stop()
+
+	logger.Info("shutting down, draining in-flight requests")
+
+	// Fail readiness first, so traffic is routed away before draining.
+	probes.SetDraining()
+	time.Sleep(cfg.ShutdownDelay)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(drainCtx); err != nil {
+		logger.WithError(err).Warn("drain timeout exceeded, closing remaining connections")
+		_ = server.Close()
+	}
+
+	// The drain may have used up drainCtx, so the metrics server gets its own.
+	metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancelMetrics()
+
+	if err := metricsServer.Shutdown(metricsCtx); err != nil {
+		logger.WithError(err).Warn("failed shutting down metrics server")
+	}
+
+	return nil
+}
+
+// middleware wraps a handler, e.g. to add headers to its responses.
+type middleware func(http.Handler) http.Handler
+
+// middlewares returns the enabled middlewares wrapping the gateway, outermost
+// first.
+func middlewares(cfg *config.Config, logger *logrus.Logger) ([]middleware, error) {
+	chain := []middleware{
+		func(next http.Handler) http.Handler {
+			// Named by method alone until the gateway mux matches a route, as
+			// paths carry IDs and would give every race its own span name.
+			return otelhttp.NewHandler(next, "api", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return "HTTP " + r.Method
+			}))
+		},
+		requestid.Middleware,
+		func(next http.Handler) http.Handler { return logging.Middleware(logger, next) },
+		metrics.Middleware,
+	}
+
+	if cfg.SecurityHeadersEnabled {
+		chain = append(chain, security.NewHeaders(security.Options{HSTSMaxAge: cfg.HSTSMaxAge}).Middleware)
+	}
+
+	// Outside the rate limiter, so preflights aren't limited and browsers can
+	// read 429s.
+	if cfg.CORSAllowedOrigins != "" {
+		origins, _ := cors.ParseOrigins(cfg.CORSAllowedOrigins)
+		chain = append(chain, cors.NewPolicy(corsOptions(origins)).Middleware)
+	}
+
+	chain = append(chain, func(next http.Handler) http.Handler {
+		return bodylimit.Middleware(int64(cfg.MaxRequestBody), next)
+	})
+
+	if cfg.CompressionEnabled {
+		chain = append(chain, compress.NewCompressor(compress.Options{MinSize: cfg.CompressionMinSize}).Middleware)
+	}
+
+	if cfg.RateLimitEnabled {
+		// Already validated with the rest of the config.
+		defaultLimit, _ := ratelimit.ParseLimit(cfg.RateLimitDefault)
+		routeLimits, _ := ratelimit.ParseRouteLimits(cfg.RateLimitRoutes)
+
+		opts := ratelimit.Options{
+			Default:        defaultLimit,
+			Routes:         routeLimits,
+			Exempt:         []string{"/healthz", "/readyz"},
+			TrustedProxies: cfg.RateLimitTrustedProxies,
+		}
+
+		var err error
+		if opts.Verifier, err = newVerifier(cfg); err != nil {
+			return nil, err
+		}
+		if cfg.RateLimitAPIKeysFile != "" {
+			if opts.APIKeys, err = ratelimit.LoadAPIKeys(cfg.RateLimitAPIKeysFile); err != nil {
+				return nil, err
+			}
+		}
+
+		chain = append(chain, ratelimit.NewLimiter(ratelimit.NewMemoryStore(), opts).Middleware)
+	}
+
+	return chain, nil
+}
+
+// newVerifier returns the verifier of bearer tokens signed with the configured
+// secret and keys, or nil when neither is set.
+func newVerifier(cfg *config.Config) (*jwtauth.Verifier, error) {
+	if cfg.AuthHMACSecret == "" && cfg.AuthJWKSFile == "" {
+		return nil, nil
+	}
+
+	var (
+		keys map[string]*rsa.PublicKey
+		err  error
+	)
+
+	if cfg.AuthJWKSFile != "" {
+		if keys, err = jwtauth.LoadJWKS(cfg.AuthJWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return jwtauth.NewVerifier([]byte(cfg.AuthHMACSecret), keys), nil
+}
+
+// chain wraps handler in middlewares, the first outermost.
+func chain(handler http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// corsOptions allows origins to call the gateway and the gRPC-Web and Connect
+// RPCs, sending and reading the headers those use.
+func corsOptions(origins []string) cors.Options {
+	return cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{
+			"Authorization", "Content-Type", "If-None-Match", ratelimit.APIKeyHeader, requestid.Header,
+			"X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "Connect-Protocol-Version", "Connect-Timeout-Ms",
+		},
+		ExposedHeaders: []string{
+			"ETag", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", requestid.Header,
+			"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin",
+		},
+		MaxAge: 10 * time.Minute,
+	}
+}
+
+// retriedRacingMethods are the racing RPCs safe to retry: reads, which have
+// no effect however many times they run. Writes such as PutRace aren't listed,
+// as a retry could repeat a write that did succeed.
+var retriedRacingMethods = []string{
+	"/racing.Racing/ListRaces",
+	"/racing.Racing/GetRace",
+	"/racing.Racing/GetRaceStats",
+	"/racing.Racing/ListNextToJump",
+}
+
+// retriedSportsMethods are the sports RPCs safe to retry, which are all reads.
+var retriedSportsMethods = []string{
+	"/sports.Sports/ListEvents",
+}
+
+// dial connects to the gRPC server at endpoint, retrying retryMethods, with the
+// connection settings shared by every backend.
+func dial(ctx context.Context, cfg *config.Config, endpoint, serverName string, retryMethods []string) (*grpc.ClientConn, error) {
+	transportCreds, err := grpcCredentials(cfg, endpoint, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	return grpcclient.Dial(ctx, grpcclient.Options{
+		Endpoint:         endpoint,
+		RetryMethods:     retryMethods,
+		RetryAttempts:    cfg.GRPCRetryAttempts,
+		KeepaliveTime:    cfg.GRPCKeepaliveTime,
+		KeepaliveTimeout: cfg.GRPCKeepaliveTimeout,
+		BreakerFailures:  cfg.GRPCBreakerFailures,
+		BreakerCooldown:  cfg.GRPCBreakerCooldown,
+	},
+		grpc.WithTransportCredentials(transportCreds),
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+	)
+}
+
+// grpcCredentials returns the transport credentials for connecting to the gRPC
+// server at endpoint: TLS (presenting a client certificate for mutual TLS when
+// one is configured) when enabled, otherwise plaintext. The server certificate
+// is verified against serverName, or the endpoint's host when it's empty.
+func grpcCredentials(cfg *config.Config, endpoint, serverName string) (credentials.TransportCredentials, error) {
+	if !cfg.GRPCTLS {
+		return insecure.NewCredentials(), nil
+	}
+
+	var (
+		rootCAs *tlsutil.CAPool
+		keyPair *tlsutil.KeyPair
+		err     error
+	)
+
+	if cfg.GRPCCAFile != "" {
+		if rootCAs, err = tlsutil.LoadCAPool(cfg.GRPCCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.GRPCCertFile != "" {
+		if keyPair, err = tlsutil.LoadKeyPair(cfg.GRPCCertFile, cfg.GRPCKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if serverName == "" {
+		if serverName, err = grpcclient.Host(endpoint); err != nil {
+			return nil, err
+		}
+	}
 
-	return http.ListenAndServe(*apiEndpoint, mux)
+	return credentials.NewTLS(tlsutil.ClientConfig(rootCAs, keyPair, serverName)), nil
 }
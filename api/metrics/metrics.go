@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/metadata"
+
+	"git.neds.sh/matty/entain/api/statusrecorder"
+)
+
+// unmatchedRoute labels requests that weren't routed to a gateway RPC, keeping
+// label cardinality bounded regardless of the paths clients request.
+const unmatchedRoute = "unmatched"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests handled, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of HTTP requests, by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+)
+
+type routeKey struct{}
+
+// route is filled in by RecordRoute once the gateway mux has matched a request.
+type route struct {
+	name string
+}
+
+// Handler serves the metrics in the default registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records a count and latency observation for every request. Routes
+// are labelled with the gRPC method they map to, which RecordRoute captures.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rt := &route{name: unmatchedRoute}
+		rec := statusrecorder.New(w)
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), routeKey{}, rt)))
+
+		labels := []string{rt.name, r.Method, strconv.Itoa(rec.Status)}
+		requestsTotal.WithLabelValues(labels...).Inc()
+		requestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RecordRoute notes which RPC the gateway mux routed the request to. It is
+// intended for use with runtime.WithMetadata, and adds no metadata itself.
+func RecordRoute(ctx context.Context, r *http.Request) metadata.MD {
+	if rt, ok := r.Context().Value(routeKey{}).(*route); ok {
+		if method, ok := runtime.RPCMethod(ctx); ok {
+			rt.name = method
+		}
+	}
+
+	return nil
+}
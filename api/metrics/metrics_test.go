@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMiddleware(t *testing.T) {
+	// Routed the way generated gateway handlers are, annotating the context
+	// with the RPC they call.
+	mux := runtime.NewServeMux(runtime.WithMetadata(RecordRoute))
+	if err := mux.HandlePath(http.MethodGet, "/v1/races/{id}", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if _, err := runtime.AnnotateContext(r.Context(), mux, r, "/racing.Racing/GetRace"); err != nil {
+			t.Fatal(err)
+		}
+		if params["id"] == "0" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := Middleware(mux)
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		wantRoute string
+		wantCode  string
+	}{
+		{name: "routed", method: http.MethodGet, target: "/v1/races/1", wantRoute: "/racing.Racing/GetRace", wantCode: "200"},
+		{name: "routed error", method: http.MethodGet, target: "/v1/races/0", wantRoute: "/racing.Racing/GetRace", wantCode: "404"},
+		{name: "unmatched", method: http.MethodGet, target: "/v1/nope/1", wantRoute: unmatchedRoute, wantCode: "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := []string{tt.wantRoute, tt.method, tt.wantCode}
+			before := testutil.ToFloat64(requestsTotal.WithLabelValues(labels...))
+			beforeCount := sampleCount(t, requestDuration.WithLabelValues(labels...))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.target, nil))
+
+			if got := testutil.ToFloat64(requestsTotal.WithLabelValues(labels...)) - before; got != 1 {
+				t.Errorf("http_requests_total%v rose by %v, want 1", labels, got)
+			}
+			if got := sampleCount(t, requestDuration.WithLabelValues(labels...)) - beforeCount; got != 1 {
+				t.Errorf("http_request_duration_seconds%v count rose by %d, want 1", labels, got)
+			}
+		})
+	}
+}
+
+// sampleCount returns the number of observations made by a histogram.
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetHistogram().GetSampleCount()
+}
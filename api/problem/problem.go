@@ -0,0 +1,183 @@
+// Package problem writes errors as RFC 7807 problem details
+// (application/problem+json), with a stable machine-readable code, in place of
+// grpc-gateway's serialized gRPC status.
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/api/logging"
+	"git.neds.sh/matty/entain/api/requestid"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Type is always about:blank,
+// so Title is the HTTP status text, and Code tells problems apart.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Code is a stable identifier of the problem: the reason of an ErrorInfo
+	// detail if the error has one, otherwise the name of its gRPC code, e.g.
+	// NOT_FOUND.
+	Code          string         `json:"code"`
+	RequestID     string         `json:"requestId,omitempty"`
+	InvalidParams []InvalidParam `json:"invalidParams,omitempty"`
+	Resource      *Resource      `json:"resource,omitempty"`
+}
+
+// InvalidParam is a request field that failed validation, named by its path
+// in the JSON request, e.g. filter.meetingIds[0].
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Resource is the resource a problem concerns, e.g. a race that wasn't found.
+type Resource struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// internalCodes are the codes whose messages may describe the gateway's or a
+// service's internals, such as addresses and queries, and so are withheld.
+var internalCodes = map[codes.Code]string{
+	codes.Unknown:     "An unexpected error occurred.",
+	codes.Internal:    "An unexpected error occurred.",
+	codes.DataLoss:    "An unexpected error occurred.",
+	codes.Unavailable: "The service is temporarily unavailable, try again later.",
+}
+
+// New creates a problem with the given HTTP status, code and detail.
+func New(httpStatus int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(httpStatus),
+		Status: httpStatus,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// FromStatus creates a problem from a gRPC status, mapping its code onto an
+// HTTP status and its BadRequest, ResourceInfo and ErrorInfo details onto
+// the problem's fields.
+func FromStatus(st *status.Status) *Problem {
+	detail, internal := internalCodes[st.Code()]
+	if !internal {
+		detail = st.Message()
+	}
+
+	p := New(runtime.HTTPStatusFromCode(st.Code()), CodeName(st.Code()), detail)
+
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, violation := range d.GetFieldViolations() {
+				p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: jsonFieldPath(violation.GetField()), Reason: violation.GetDescription()})
+			}
+		case *errdetails.ResourceInfo:
+			p.Resource = &Resource{Type: d.GetResourceType(), Name: d.GetResourceName()}
+		case *errdetails.ErrorInfo:
+			if d.GetReason() != "" {
+				p.Code = d.GetReason()
+			}
+		}
+	}
+
+	return p
+}
+
+// jsonFieldPath converts a field path of proto names, as the racing service
+// reports violations, to the JSON names the gateway's clients use, e.g.
+// filter.meeting_ids[0] to filter.meetingIds[0]. It follows protojson, which
+// drops underscores and upper-cases the lower case letters after them.
+func jsonFieldPath(path string) string {
+	var (
+		b               strings.Builder
+		afterUnderscore bool
+	)
+	for _, r := range path {
+		if r == '_' {
+			afterUnderscore = true
+			continue
+		}
+		if afterUnderscore && 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		b.WriteRune(r)
+		afterUnderscore = false
+	}
+
+	return b.String()
+}
+
+// CodeName returns the canonical name of a gRPC code, e.g. INVALID_ARGUMENT.
+func CodeName(code codes.Code) string {
+	var (
+		b    strings.Builder
+		prev rune
+	)
+	for _, r := range code.String() {
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+
+	return strings.ToUpper(b.String())
+}
+
+// Write writes p as the response to r, filling in its instance and request ID.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = requestid.FromContext(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(p.Status)
+
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// ErrorHandler writes gateway errors as problem details. It is intended for
+// use with runtime.WithErrorHandler. Messages withheld from the caller are
+// logged instead.
+func ErrorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+
+	if _, internal := internalCodes[st.Code()]; internal {
+		logging.FromContext(r.Context()).WithError(err).Warn("withheld error details from caller")
+	}
+
+	// Forward the header metadata the service sent, as the default handler does.
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		for key, values := range md.HeaderMD {
+			for _, value := range values {
+				w.Header().Add(runtime.MetadataHeaderPrefix+key, value)
+			}
+		}
+	}
+
+	// Unauthenticated requests need a challenge, per RFC 7235.
+	if st.Code() == codes.Unauthenticated {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	Write(w, r, FromStatus(st))
+}
@@ -0,0 +1,141 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/api/requestid"
+)
+
+func TestFromStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  *status.Status
+		details []*errdetails.BadRequest
+		info    *errdetails.ResourceInfo
+		reason  string
+		want    *Problem
+	}{
+		{
+			name:   "bad request",
+			status: status.New(codes.InvalidArgument, "page_size: must not be negative"),
+			details: []*errdetails.BadRequest{{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "page_size", Description: "must not be negative"},
+				{Field: "filter.meeting_ids[0]", Description: "must be greater than 0"},
+			}}},
+			want: &Problem{
+				Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "page_size: must not be negative", Code: "INVALID_ARGUMENT",
+				InvalidParams: []InvalidParam{
+					{Name: "pageSize", Reason: "must not be negative"},
+					{Name: "filter.meetingIds[0]", Reason: "must be greater than 0"},
+				},
+			},
+		},
+		{
+			name:   "resource",
+			status: status.New(codes.NotFound, "race 5 not found"),
+			info:   &errdetails.ResourceInfo{ResourceType: "racing.Race", ResourceName: "5"},
+			want: &Problem{
+				Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "race 5 not found", Code: "NOT_FOUND",
+				Resource: &Resource{Type: "racing.Race", Name: "5"},
+			},
+		},
+		{
+			name:   "error info",
+			status: status.New(codes.FailedPrecondition, "race closed"),
+			reason: "RACE_CLOSED",
+			want: &Problem{
+				Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "race closed", Code: "RACE_CLOSED",
+			},
+		},
+		{
+			name:   "internal",
+			status: status.New(codes.Unavailable, "connection error: dial tcp 10.0.0.1:9000: connection refused"),
+			want: &Problem{
+				Type: "about:blank", Title: "Service Unavailable", Status: http.StatusServiceUnavailable,
+				Detail: internalCodes[codes.Unavailable], Code: "UNAVAILABLE",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.status
+			for _, d := range tt.details {
+				st, _ = st.WithDetails(d)
+			}
+			if tt.info != nil {
+				st, _ = st.WithDetails(tt.info)
+			}
+			if tt.reason != "" {
+				st, _ = st.WithDetails(&errdetails.ErrorInfo{Reason: tt.reason})
+			}
+
+			if got := FromStatus(st); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeName(t *testing.T) {
+	for code, want := range map[codes.Code]string{
+		codes.OK:                "OK",
+		codes.NotFound:          "NOT_FOUND",
+		codes.ResourceExhausted: "RESOURCE_EXHAUSTED",
+	} {
+		if got := CodeName(code); got != want {
+			t.Errorf("CodeName(%v) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithErrorHandler(ErrorHandler))
+	if err := mux.HandlePath(http.MethodGet, "/v1/races/{id}", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		runtime.HTTPError(r.Context(), mux, &runtime.JSONPb{}, w, r, status.Error(codes.Unauthenticated, "missing bearer token"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path          string
+		wantStatus    int
+		wantCode      string
+		wantChallenge bool
+	}{
+		{path: "/v1/races/5", wantStatus: http.StatusUnauthorized, wantCode: "UNAUTHENTICATED", wantChallenge: true},
+		{path: "/v1/nope", wantStatus: http.StatusNotFound, wantCode: "NOT_FOUND"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		requestid.Middleware(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != tt.wantStatus || rec.Header().Get("Content-Type") != ContentType {
+			t.Errorf("GET %s = %d %q, want %d %s", tt.path, rec.Code, rec.Header().Get("Content-Type"), tt.wantStatus, ContentType)
+		}
+		if got := rec.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+			t.Errorf("GET %s challenged = %v, want %v", tt.path, got, tt.wantChallenge)
+		}
+
+		var got Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != tt.wantCode || got.Status != tt.wantStatus || got.Instance != tt.path || got.RequestID != rec.Header().Get(requestid.Header) {
+			t.Errorf("GET %s = %+v", tt.path, got)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// minAPIKeyLength is the shortest API key accepted, so keys can't be guessed.
+const minAPIKeyLength = 16
+
+// LoadAPIKeys reads a YAML file mapping client names to their API key, e.g.
+// "trading-tools: 3b1f...", returning the keys mapped to their client's name.
+func LoadAPIKeys(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients map[string]string
+	if err := yaml.UnmarshalStrict(b, &clients); err != nil {
+		return nil, fmt.Errorf("invalid API keys file %s: %w", path, err)
+	}
+
+	keys := make(map[string]string, len(clients))
+	for name, key := range clients {
+		if len(key) < minAPIKeyLength {
+			return nil, fmt.Errorf("invalid API keys file %s: key of %s must be at least %d characters", path, name, minAPIKeyLength)
+		}
+		if other, ok := keys[key]; ok {
+			return nil, fmt.Errorf("invalid API keys file %s: %s and %s share a key", path, other, name)
+		}
+
+		keys[key] = name
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period, as a token bucket holding up to Requests
+// tokens that refills continuously over Period, so short bursts are absorbed.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses a limit in the form "<requests>/<period>", e.g. "100/1m".
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("limit %q: want <requests>/<period>", s)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: requests must be a positive integer", s)
+	}
+
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: period must be a positive duration", s)
+	}
+
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+// ParseRouteLimits parses per-route limits in the form
+// "<path>=<requests>/<period>,...", e.g. "/v1/list-races=20/1s".
+func ParseRouteLimits(s string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+
+	if s == "" {
+		return limits, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") {
+			return nil, fmt.Errorf("route limit %q: want <path>=<requests>/<period>", entry)
+		}
+
+		limit, err := ParseLimit(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		limits[parts[0]] = limit
+	}
+
+	return limits, nil
+}
+
+// String formats the limit as accepted by ParseLimit.
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + l.Period.String()
+}
+
+// rate returns how many tokens are added to the bucket per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
@@ -0,0 +1,169 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"git.neds.sh/matty/entain/api/logging"
+	"git.neds.sh/matty/entain/api/problem"
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+)
+
+// Options configures a Limiter.
+type Options struct {
+	// Default applies to every path without a route limit.
+	Default Limit
+	// Routes maps request paths to their own limit.
+	Routes map[string]Limit
+	// Exempt paths, such as health probes, are never limited.
+	Exempt []string
+	// TrustedProxies is the number of proxies in front of the gateway, each
+	// appending the address it received the request from to X-Forwarded-For.
+	// Clients are keyed by the address the outermost of them saw, which is that
+	// many entries from the right; anything further left is set by the client.
+	// When 0, X-Forwarded-For is ignored.
+	TrustedProxies int
+	// Verifier, when set, verifies bearer tokens, so clients presenting a
+	// valid one are keyed by its subject.
+	Verifier *jwtauth.Verifier
+	// APIKeys maps the API keys clients may present in X-Api-Key to the name
+	// of the client they belong to, which the client is keyed by.
+	APIKeys map[string]string
+}
+
+// Limiter rate limits requests per client and route.
+type Limiter struct {
+	store  Store
+	opts   Options
+	exempt map[string]bool
+	// apiKeys maps the SHA-256 of each API key to its client's name, so
+	// looking a key up takes the same time however much of it is right.
+	apiKeys map[[sha256.Size]byte]string
+}
+
+// NewLimiter creates a new limiter keeping its state in store.
+func NewLimiter(store Store, opts Options) *Limiter {
+	exempt := make(map[string]bool, len(opts.Exempt))
+	for _, path := range opts.Exempt {
+		exempt[path] = true
+	}
+
+	apiKeys := make(map[[sha256.Size]byte]string, len(opts.APIKeys))
+	for key, name := range opts.APIKeys {
+		apiKeys[sha256.Sum256([]byte(key))] = name
+	}
+
+	return &Limiter{store: store, opts: opts, exempt: exempt, apiKeys: apiKeys}
+}
+
+// Middleware rejects requests over their limit with 429 Too Many Requests and
+// a Retry-After header, and reports the client's quota on every response
+// through the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// If the store fails, requests are let through rather than failing the API.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.exempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit, ok := l.opts.Routes[r.URL.Path]
+		if !ok {
+			limit = l.opts.Default
+		}
+
+		// Unrouted paths share a bucket, so random paths can't mint new ones.
+		bucket := "*"
+		if ok {
+			bucket = r.URL.Path
+		}
+
+		res, err := l.store.Take(r.Context(), bucket+"|"+l.clientKey(r), limit)
+		if err != nil {
+			logging.FromContext(r.Context()).WithError(err).Warn("rate limit store unavailable, allowing request")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+			problem.Write(w, r, problem.New(http.StatusTooManyRequests, problem.CodeName(codes.ResourceExhausted), "rate limit exceeded"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APIKeyHeader is the header clients present their API key in.
+const APIKeyHeader = "X-Api-Key"
+
+// clientKey identifies who a request is counted against: the subject of a
+// verified bearer token, else the client owning a known API key, else its IP
+// address. Credentials that don't verify are ignored rather than keyed on, so
+// presenting a fresh one each time doesn't earn a fresh bucket.
+func (l *Limiter) clientKey(r *http.Request) string {
+	if l.opts.Verifier != nil {
+		if token, ok := bearerToken(r); ok {
+			if claims, err := l.opts.Verifier.Verify(token); err == nil {
+				return "sub:" + claims.Subject
+			}
+		}
+	}
+
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		if name, ok := l.apiKeys[sha256.Sum256([]byte(key))]; ok {
+			return "key:" + name
+		}
+	}
+
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP returns the address of the client, as seen by the outermost
+// trusted proxy, or by the gateway when there are none.
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.opts.TrustedProxies > 0 {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) != 0 {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			if len(hops) >= l.opts.TrustedProxies {
+				if ip := strings.TrimSpace(hops[len(hops)-l.opts.TrustedProxies]); ip != "" {
+					return ip
+				}
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return host
+}
+
+// bearerToken returns the token from the request's Authorization header, if any.
+func bearerToken(r *http.Request) (string, bool) {
+	value := r.Header.Get("Authorization")
+	if len(value) > len("bearer ") && strings.EqualFold(value[:len("bearer ")], "bearer ") {
+		return value[len("bearer "):], true
+	}
+
+	return "", false
+}
+
+// ceilSeconds formats d as a whole number of seconds, rounding up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,286 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+	"git.neds.sh/matty/entain/pkg/jwtauth/jwtauthtest"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "100/1m", want: Limit{Requests: 100, Period: time.Minute}},
+		{in: "5/1s", want: Limit{Requests: 5, Period: time.Second}},
+		{in: "100", wantErr: true},
+		{in: "0/1s", wantErr: true},
+		{in: "5/soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	routes, err := ParseRouteLimits("/v1/list-races=20/1s,/v1/race-stats=5/1s")
+	if err != nil || len(routes) != 2 || routes["/v1/race-stats"] != (Limit{5, time.Second}) {
+		t.Errorf("ParseRouteLimits() = %v, %v", routes, err)
+	}
+	if _, err := ParseRouteLimits("list-races=20/1s"); err == nil {
+		t.Error("ParseRouteLimits() expected an error for a relative path")
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := &memoryStore{now: func() time.Time { return now }, buckets: make(map[string]*bucket), sweptAt: now}
+	limit := Limit{Requests: 2, Period: 2 * time.Second}
+
+	take := func(key string) Result {
+		res, err := store.Take(context.Background(), key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if res := take("a"); !res.Allowed || res.Remaining != 1 || res.Reset != time.Second {
+		t.Errorf("first take = %+v", res)
+	}
+	if res := take("a"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("second take = %+v", res)
+	}
+	if res := take("a"); res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("third take = %+v, want denied with a 1s retry", res)
+	}
+	if res := take("b"); !res.Allowed {
+		t.Errorf("other key take = %+v, want allowed", res)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res := take("a"); res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("take after partial refill = %+v", res)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res := take("a"); !res.Allowed {
+		t.Errorf("take after refill = %+v, want allowed", res)
+	}
+
+	// Refilled buckets are swept away.
+	now = now.Add(sweepInterval)
+	take("c")
+	if _, ok := store.buckets["a"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), Options{
+		Default: Limit{Requests: 1, Period: time.Minute},
+		Routes:  map[string]Limit{"/v1/list-races": {Requests: 2, Period: time.Minute}},
+		Exempt:  []string{"/healthz"},
+	})
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(remoteAddr, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do("10.0.0.1:1234", "/v1/list-races"); w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200", i, w.Code)
+		}
+	}
+
+	w := do("10.0.0.1:1234", "/v1/list-races")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over limit = %d, want 429", w.Code)
+	}
+	for header, want := range map[string]string{
+		"Retry-After":         "30",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"Content-Type":        "application/problem+json",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// Other routes, clients and exempt paths have their own allowance.
+	if w := do("10.0.0.1:1234", "/v1/race-stats"); w.Code != http.StatusOK {
+		t.Errorf("other route = %d, want 200", w.Code)
+	}
+	if w := do("10.0.0.2:1234", "/v1/list-races"); w.Code != http.StatusOK {
+		t.Errorf("other client = %d, want 200", w.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := do("10.0.0.1:1234", "/healthz"); w.Code != http.StatusOK {
+			t.Errorf("exempt path = %d, want 200", w.Code)
+		}
+	}
+}
+
+var (
+	secret = []byte("test-secret")
+	apiKey = "0123456789abcdef"
+)
+
+func TestLimiter_clientKey(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	valid := jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "alice", expiry)
+	forged := jwtauthtest.Sign(t, jwt.SigningMethodHS256, []byte("other"), "", "mallory", expiry)
+
+	tests := []struct {
+		name    string
+		proxies int
+		header  http.Header
+		want    string
+	}{
+		{name: "anonymous", want: "ip:10.0.0.1"},
+		{name: "verified token", header: http.Header{"Authorization": {"Bearer " + valid}}, want: "sub:alice"},
+		{name: "forged token", header: http.Header{"Authorization": {"Bearer " + forged}}, want: "ip:10.0.0.1"},
+		{name: "known API key", header: http.Header{APIKeyHeader: {apiKey}}, want: "key:tools"},
+		{name: "unknown API key", header: http.Header{APIKeyHeader: {"fedcba9876543210"}}, want: "ip:10.0.0.1"},
+		{name: "token over API key", header: http.Header{"Authorization": {"Bearer " + valid}, APIKeyHeader: {apiKey}}, want: "sub:alice"},
+		{name: "untrusted forwarded for", header: http.Header{"X-Forwarded-For": {"1.2.3.4"}}, want: "ip:10.0.0.1"},
+		{name: "one proxy", proxies: 1, header: http.Header{"X-Forwarded-For": {"6.6.6.6, 1.2.3.4"}}, want: "ip:1.2.3.4"},
+		{name: "two proxies", proxies: 2, header: http.Header{"X-Forwarded-For": {"6.6.6.6, 1.2.3.4, 10.0.0.9"}}, want: "ip:1.2.3.4"},
+		{name: "split header", proxies: 2, header: http.Header{"X-Forwarded-For": {"6.6.6.6, 1.2.3.4", "10.0.0.9"}}, want: "ip:1.2.3.4"},
+		{name: "fewer hops than proxies", proxies: 2, header: http.Header{"X-Forwarded-For": {"1.2.3.4"}}, want: "ip:10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(NewMemoryStore(), Options{
+				TrustedProxies: tt.proxies,
+				Verifier:       jwtauth.NewVerifier(secret, nil),
+				APIKeys:        map[string]string{apiKey: "tools"},
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/list-races", nil)
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.Header = tt.header.Clone()
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+
+			if got := l.clientKey(r); got != tt.want {
+				t.Errorf("clientKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_Middleware_rotatingCredentials(t *testing.T) {
+	handler := NewLimiter(NewMemoryStore(), Options{
+		Default:        Limit{Requests: 3, Period: time.Minute},
+		TrustedProxies: 1,
+		Verifier:       jwtauth.NewVerifier(secret, nil),
+		APIKeys:        map[string]string{apiKey: "tools"},
+	}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Each request presents fresh credentials the gateway can't verify, and a
+	// fresh address the client put in X-Forwarded-For itself.
+	for i := 0; i < 5; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/v1/list-races", nil)
+		r.RemoteAddr = "10.0.0.9:1234"
+		r.Header.Set("X-Forwarded-For", fmt.Sprintf("6.6.6.%d, 1.2.3.4", i))
+		r.Header.Set(APIKeyHeader, fmt.Sprintf("unknown-key-%06d", i))
+		r.Header.Set("Authorization", "Bearer "+jwtauthtest.Sign(t, jwt.SigningMethodHS256, []byte("other"), "", fmt.Sprint(i), time.Now().Add(time.Hour)))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		want := http.StatusOK
+		if i >= 3 {
+			want = http.StatusTooManyRequests
+		}
+		if w.Code != want {
+			t.Errorf("request %d with fresh credentials = %d, want %d", i, w.Code, want)
+		}
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "valid", file: "tools: 0123456789abcdef\nfeeds: fedcba9876543210\n", want: map[string]string{"0123456789abcdef": "tools", "fedcba9876543210": "feeds"}},
+		{name: "short key", file: "tools: abc\n", wantErr: "key of tools must be at least 16 characters"},
+		{name: "shared key", file: "a: 0123456789abcdef\nb: 0123456789abcdef\n", wantErr: "share a key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadAPIKeys(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadAPIKeys() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadAPIKeys() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadAPIKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_Middleware_storeError(t *testing.T) {
+	handler := NewLimiter(failingStore{}, Options{Default: Limit{Requests: 1, Period: time.Minute}}).
+		Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/list-races", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("request with failing store = %d, want 200", w.Code)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("unavailable")
+}
@@ -0,0 +1,86 @@
+// Package requestid gives every request an ID, which is logged, echoed back to
+// the caller and forwarded to backend services.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Header is the HTTP header carrying the request ID.
+	Header = "X-Request-Id"
+	// MetadataKey is the gRPC metadata key the request ID is forwarded under.
+	MetadataKey = "x-request-id"
+)
+
+// maxLength caps the length of request IDs accepted from callers.
+const maxLength = 128
+
+type idKey struct{}
+
+// Middleware assigns each request an ID, reusing the caller's X-Request-Id
+// when it's a plausible ID, and echoes it back in the response. Anything else
+// is replaced, so callers can't inject arbitrary text into logs.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = newID()
+			r.Header.Set(Header, id)
+		}
+		w.Header().Set(Header, id)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), idKey{}, id)))
+	})
+}
+
+// FromContext returns the ID of the request ctx belongs to, or "" outside of
+// a request.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// OutgoingMetadata forwards the request ID to backend services. It is intended
+// for use with runtime.WithMetadata on the gateway mux.
+func OutgoingMetadata(_ context.Context, r *http.Request) metadata.MD {
+	id := FromContext(r.Context())
+	if id == "" {
+		return nil
+	}
+
+	return metadata.Pairs(MetadataKey, id)
+}
+
+// valid reports whether id is non-empty, reasonably short, and made up of
+// characters found in common ID formats such as UUIDs.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,49 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantKept bool
+	}{
+		{name: "none"},
+		{name: "uuid", incoming: "3f1c2b8e-1d2a-4c5b-9e8f-0a1b2c3d4e5f", wantKept: true},
+		{name: "log injection", incoming: "abc\nlevel=error"},
+		{name: "too long", incoming: string(make([]byte, maxLength+1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCtx, gotHeader string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotCtx = FromContext(r.Context())
+				gotHeader = r.Header.Get(Header)
+
+				if md := OutgoingMetadata(r.Context(), r); len(md.Get(MetadataKey)) != 1 || md.Get(MetadataKey)[0] != gotCtx {
+					t.Errorf("OutgoingMetadata() = %v, want %s", md, gotCtx)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(Header, tt.incoming)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if gotCtx == "" || gotCtx != gotHeader || rec.Header().Get(Header) != gotCtx {
+				t.Fatalf("context ID %q, request header %q and response header %q should match", gotCtx, gotHeader, rec.Header().Get(Header))
+			}
+			if kept := gotCtx == tt.incoming; kept != tt.wantKept {
+				t.Errorf("kept caller's ID = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
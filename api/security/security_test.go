@@ -0,0 +1,67 @@
+package security
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeaders_Middleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      bool
+		ownCSP   string
+		wantCSP  string
+		wantHSTS string
+	}{
+		{
+			name:    "http",
+			wantCSP: DefaultContentSecurityPolicy,
+		},
+		{
+			name:     "https",
+			tls:      true,
+			wantCSP:  DefaultContentSecurityPolicy,
+			wantHSTS: "max-age=3600; includeSubDomains",
+		},
+		{
+			name:    "handler's own policy",
+			ownCSP:  "default-src 'self'",
+			wantCSP: "default-src 'self'",
+		},
+	}
+
+	headers := NewHeaders(Options{HSTSMaxAge: time.Hour})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := headers.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.ownCSP != "" {
+					w.Header().Set("Content-Security-Policy", tt.ownCSP)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/races", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			for header, want := range map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "no-referrer",
+				"Content-Security-Policy":   tt.wantCSP,
+				"Strict-Transport-Security": tt.wantHSTS,
+			} {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Package statusrecorder captures the status code a handler responds with, for
+// middleware that reports on it once the handler returns.
+package statusrecorder
+
+import "net/http"
+
+// Recorder wraps a ResponseWriter, noting the status code written through it.
+type Recorder struct {
+	http.ResponseWriter
+	// Status is the status code written, or 200 OK if the handler wrote none.
+	Status int
+}
+
+// New creates a recorder wrapping w.
+func New(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	r.Status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,62 @@
+package upcoming
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// RacingSource lists the next visible races.
+type RacingSource struct {
+	client racing.RacingClient
+}
+
+// NewRacingSource creates a source listing races with client.
+func NewRacingSource(client racing.RacingClient) *RacingSource {
+	return &RacingSource{client: client}
+}
+
+// Name returns "racing".
+func (s *RacingSource) Name() string {
+	return "racing"
+}
+
+// Upcoming lists the first limit visible races starting from from, in start
+// time order, with a single ListRaces call.
+func (s *RacingSource) Upcoming(ctx context.Context, from time.Time, limit int) ([]Item, error) {
+	resp, err := s.client.ListRaces(ctx, &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{
+			Visible:                 proto.Bool(true),
+			AdvertisedStartTimeFrom: timestamppb.New(from),
+		},
+		OrderBy:  "advertised_start_time",
+		PageSize: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(resp.GetRaces()))
+	for _, race := range resp.GetRaces() {
+		details, err := protojson.Marshal(race)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, Item{
+			Kind:                "race",
+			ID:                  strconv.FormatInt(race.GetId(), 10),
+			Name:                race.GetName(),
+			AdvertisedStartTime: race.GetAdvertisedStartTime().AsTime(),
+			Details:             details,
+		})
+	}
+
+	return items, nil
+}
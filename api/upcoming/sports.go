@@ -0,0 +1,61 @@
+package upcoming
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/proto/sports"
+)
+
+// SportsSource lists the next visible sports events.
+type SportsSource struct {
+	client sports.SportsClient
+}
+
+// NewSportsSource creates a source listing sports events with client.
+func NewSportsSource(client sports.SportsClient) *SportsSource {
+	return &SportsSource{client: client}
+}
+
+// Name returns "sports".
+func (s *SportsSource) Name() string {
+	return "sports"
+}
+
+// Upcoming lists the first limit visible events starting from from, in start
+// time order, with a single ListEvents call.
+func (s *SportsSource) Upcoming(ctx context.Context, from time.Time, limit int) ([]Item, error) {
+	resp, err := s.client.ListEvents(ctx, &sports.ListEventsRequest{
+		Filter: &sports.ListEventsRequestFilter{
+			Visible:                 proto.Bool(true),
+			AdvertisedStartTimeFrom: timestamppb.New(from),
+		},
+		PageSize: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(resp.GetEvents()))
+	for _, event := range resp.GetEvents() {
+		details, err := protojson.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, Item{
+			Kind:                "event",
+			ID:                  strconv.FormatInt(event.GetId(), 10),
+			Name:                event.GetName(),
+			AdvertisedStartTime: event.GetAdvertisedStartTime().AsTime(),
+			Details:             details,
+		})
+	}
+
+	return items, nil
+}
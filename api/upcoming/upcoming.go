@@ -0,0 +1,160 @@
+// Package upcoming serves /v1/upcoming, which lists what's on next across
+// every backend, merged into a single list by start time.
+package upcoming
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/api/logging"
+	"git.neds.sh/matty/entain/api/problem"
+)
+
+// Path is the route the aggregate is served on.
+const Path = "/v1/upcoming"
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Item is something upcoming, such as a race.
+type Item struct {
+	// Kind is the kind of item, e.g. race.
+	Kind string `json:"kind"`
+	// ID identifies the item among those of its kind.
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	AdvertisedStartTime time.Time `json:"advertisedStartTime"`
+	// Details is the backend's own JSON representation of the item.
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// Source lists upcoming items from one backend.
+type Source interface {
+	// Name identifies the source in warnings, e.g. racing.
+	Name() string
+	// Upcoming returns up to limit items starting at or after from, ordered
+	// by start time.
+	Upcoming(ctx context.Context, from time.Time, limit int) ([]Item, error)
+}
+
+// Warning reports a source whose items are missing from a response.
+type Warning struct {
+	Source string `json:"source"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// response is the JSON body of /v1/upcoming.
+type response struct {
+	Items    []Item    `json:"items"`
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// Aggregator merges the upcoming items of several sources.
+type Aggregator struct {
+	mux     *runtime.ServeMux
+	timeout time.Duration
+	sources []Source
+	now     func() time.Time
+}
+
+// Register serves the aggregate of sources on mux. Each source gets at most
+// timeout to answer, so a slow backend can't hold up the others.
+func Register(mux *runtime.ServeMux, timeout time.Duration, sources ...Source) error {
+	a := &Aggregator{mux: mux, timeout: timeout, sources: sources, now: time.Now}
+
+	return mux.HandlePath(http.MethodGet, Path, a.Serve)
+}
+
+// Serve lists the next items across every source, up to the limit query
+// parameter. Sources that fail are reported as warnings alongside the items of
+// the others, and the request only fails when every source does.
+func (a *Aggregator) Serve(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	limit := defaultLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxLimit {
+			p := problem.New(http.StatusBadRequest, problem.CodeName(codes.InvalidArgument), "limit: must be between 1 and 100")
+			p.InvalidParams = []problem.InvalidParam{{Name: "limit", Reason: "must be between 1 and 100"}}
+			problem.Write(w, r, p)
+			return
+		}
+	}
+
+	// Annotating the context as the gateway's own routes do forwards the
+	// caller's credentials and request ID, and applies any Grpc-Timeout.
+	ctx, err := runtime.AnnotateContext(r.Context(), a.mux, r, Path)
+	if err != nil {
+		problem.Write(w, r, problem.FromStatus(status.Convert(err)))
+		return
+	}
+
+	from := a.now()
+	items := make([][]Item, len(a.sources))
+	errs := make([]error, len(a.sources))
+
+	var wg sync.WaitGroup
+	for i, source := range a.sources {
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, a.timeout)
+			defer cancel()
+
+			items[i], errs[i] = source.Upcoming(ctx, from, limit)
+		}(i, source)
+	}
+	wg.Wait()
+
+	resp := response{Items: []Item{}}
+	var failed *status.Status
+
+	for i, source := range a.sources {
+		if errs[i] == nil {
+			resp.Items = append(resp.Items, items[i]...)
+			continue
+		}
+
+		st := status.Convert(errs[i])
+		if failed == nil {
+			failed = st
+		}
+
+		logging.FromContext(r.Context()).WithError(errs[i]).WithField("source", source.Name()).Warn("upcoming source failed")
+
+		p := problem.FromStatus(st)
+		resp.Warnings = append(resp.Warnings, Warning{Source: source.Name(), Code: p.Code, Detail: p.Detail})
+	}
+
+	if len(resp.Warnings) == len(a.sources) && failed != nil {
+		problem.Write(w, r, problem.FromStatus(failed))
+		return
+	}
+
+	sort.SliceStable(resp.Items, func(i, j int) bool {
+		return resp.Items[i].AdvertisedStartTime.Before(resp.Items[j].AdvertisedStartTime)
+	})
+	if len(resp.Items) > limit {
+		resp.Items = resp.Items[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Partial results mustn't be cached in place of complete ones.
+	if len(resp.Warnings) != 0 {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
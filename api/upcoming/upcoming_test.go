@@ -0,0 +1,273 @@
+package upcoming
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/proto/sports"
+)
+
+var now = time.Date(2021, 2, 24, 9, 0, 0, 0, time.UTC)
+
+// staticSource answers with items or err, or waits for its context to end
+// when slow.
+type staticSource struct {
+	name  string
+	items []Item
+	err   error
+	slow  bool
+}
+
+func (s staticSource) Name() string { return s.name }
+
+func (s staticSource) Upcoming(ctx context.Context, _ time.Time, _ int) ([]Item, error) {
+	if s.slow {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	return s.items, s.err
+}
+
+func item(kind, id string, in time.Duration) Item {
+	return Item{Kind: kind, ID: id, Name: kind + " " + id, AdvertisedStartTime: now.Add(in)}
+}
+
+func TestAggregator_Serve(t *testing.T) {
+	races := staticSource{name: "racing", items: []Item{item("race", "1", time.Minute), item("race", "2", 3*time.Minute)}}
+	events := staticSource{name: "sports", items: []Item{item("event", "1", 2*time.Minute)}}
+	down := staticSource{name: "sports", err: status.Error(codes.Unavailable, "connection refused")}
+	slow := staticSource{name: "sports", slow: true}
+
+	tests := []struct {
+		name         string
+		sources      []Source
+		query        string
+		header       http.Header
+		wantStatus   int
+		wantIDs      []string
+		wantWarnings []string
+	}{
+		{
+			name:       "merged by start time",
+			sources:    []Source{races, events},
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{"race 1", "event 1", "race 2"},
+		},
+		{
+			name:       "limit",
+			sources:    []Source{races, events},
+			query:      "?limit=2",
+			wantStatus: http.StatusOK,
+			wantIDs:    []string{"race 1", "event 1"},
+		},
+		{
+			name:         "failed source",
+			sources:      []Source{races, down},
+			wantStatus:   http.StatusOK,
+			wantIDs:      []string{"race 1", "race 2"},
+			wantWarnings: []string{"sports UNAVAILABLE"},
+		},
+		{
+			name:         "slow source",
+			sources:      []Source{races, slow},
+			wantStatus:   http.StatusOK,
+			wantIDs:      []string{"race 1", "race 2"},
+			wantWarnings: []string{"sports DEADLINE_EXCEEDED"},
+		},
+		{
+			name:         "request deadline",
+			sources:      []Source{races, slow},
+			header:       http.Header{"Grpc-Timeout": {"10m"}},
+			wantStatus:   http.StatusOK,
+			wantIDs:      []string{"race 1", "race 2"},
+			wantWarnings: []string{"sports DEADLINE_EXCEEDED"},
+		},
+		{
+			name:       "every source failed",
+			sources:    []Source{down},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "invalid limit",
+			sources:    []Source{races},
+			query:      "?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := 50 * time.Millisecond
+			if tt.header != nil {
+				// Long enough that only the request's own deadline can expire.
+				timeout = time.Minute
+			}
+
+			a := &Aggregator{mux: runtime.NewServeMux(), timeout: timeout, sources: tt.sources, now: func() time.Time { return now }}
+
+			req := httptest.NewRequest(http.MethodGet, Path+tt.query, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+
+			rec := httptest.NewRecorder()
+			a.Serve(rec, req, nil)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			var ids, warnings []string
+			for _, item := range resp.Items {
+				ids = append(ids, item.Kind+" "+item.ID)
+			}
+			for _, warning := range resp.Warnings {
+				warnings = append(warnings, warning.Source+" "+warning.Code)
+			}
+
+			if !equal(ids, tt.wantIDs) {
+				t.Errorf("items = %v, want %v", ids, tt.wantIDs)
+			}
+			if !equal(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %v, want %v", warnings, tt.wantWarnings)
+			}
+			if got, want := rec.Header().Get("Cache-Control") == "no-store", len(tt.wantWarnings) != 0; got != want {
+				t.Errorf("Cache-Control = %q, want no-store only for partial results", rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+// recordingRacingClient records the last ListRaces request and serves races.
+type recordingRacingClient struct {
+	racing.RacingClient
+	races []*racing.Race
+	got   *racing.ListRacesRequest
+	calls int
+}
+
+func (c *recordingRacingClient) ListRaces(_ context.Context, in *racing.ListRacesRequest, _ ...grpc.CallOption) (*racing.ListRacesResponse, error) {
+	c.calls++
+	c.got = in
+
+	return &racing.ListRacesResponse{Races: c.races}, nil
+}
+
+func TestRacingSource_Upcoming(t *testing.T) {
+	client := &recordingRacingClient{races: []*racing.Race{
+		{Id: 3, Name: "Race 3", AdvertisedStartTime: timestamppb.New(now)},
+		{Id: 4, Name: "Race 4", AdvertisedStartTime: timestamppb.New(now.Add(time.Minute))},
+	}}
+
+	items, err := NewRacingSource(client).Upcoming(context.Background(), now, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{
+			Visible:                 proto.Bool(true),
+			AdvertisedStartTimeFrom: timestamppb.New(now),
+		},
+		OrderBy:  "advertised_start_time",
+		PageSize: 2,
+	}
+	if client.calls != 1 || !proto.Equal(client.got, want) {
+		t.Errorf("ListRaces called %d times, last with %v, want once with %v", client.calls, client.got, want)
+	}
+
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+		if item.Kind != "race" || !strings.Contains(string(item.Details), `"name":"Race `+item.ID+`"`) {
+			t.Errorf("item = %+v, want race %s with its details", item, item.ID)
+		}
+	}
+	if !equal(ids, []string{"3", "4"}) {
+		t.Errorf("Upcoming() IDs = %v, want [3 4]", ids)
+	}
+}
+
+// recordingSportsClient records the last ListEvents request and serves events.
+type recordingSportsClient struct {
+	sports.SportsClient
+	events []*sports.Event
+	got    *sports.ListEventsRequest
+	calls  int
+}
+
+func (c *recordingSportsClient) ListEvents(_ context.Context, in *sports.ListEventsRequest, _ ...grpc.CallOption) (*sports.ListEventsResponse, error) {
+	c.calls++
+	c.got = in
+
+	return &sports.ListEventsResponse{Events: c.events}, nil
+}
+
+func TestSportsSource_Upcoming(t *testing.T) {
+	client := &recordingSportsClient{events: []*sports.Event{
+		{Id: 7, Name: "Event 7", Sport: "AFL", AdvertisedStartTime: timestamppb.New(now)},
+		{Id: 8, Name: "Event 8", Sport: "NRL", AdvertisedStartTime: timestamppb.New(now.Add(time.Minute))},
+	}}
+
+	items, err := NewSportsSource(client).Upcoming(context.Background(), now, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &sports.ListEventsRequest{
+		Filter: &sports.ListEventsRequestFilter{
+			Visible:                 proto.Bool(true),
+			AdvertisedStartTimeFrom: timestamppb.New(now),
+		},
+		PageSize: 2,
+	}
+	if client.calls != 1 || !proto.Equal(client.got, want) {
+		t.Errorf("ListEvents called %d times, last with %v, want once with %v", client.calls, client.got, want)
+	}
+
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+		if item.Kind != "event" || !strings.Contains(string(item.Details), `"name":"Event `+item.ID+`"`) {
+			t.Errorf("item = %+v, want event %s with its details", item, item.ID)
+		}
+	}
+	if !equal(ids, []string{"7", "8"}) {
+		t.Errorf("Upcoming() IDs = %v, want [7 8]", ids)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,163 @@
+package webrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Content types of Connect unary requests. Streaming requests, which use
+// application/connect+proto and application/connect+json, aren't supported.
+const (
+	connectProtoContentType = "application/proto"
+	connectJSONContentType  = "application/json"
+)
+
+// connectHTTPStatus maps gRPC codes onto the HTTP status codes of Connect
+// protocol errors.
+var connectHTTPStatus = map[codes.Code]int{
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// connectError is the JSON body of a Connect protocol error.
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+type connectDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// serveConnect serves a Connect protocol unary request, whose body is a bare
+// message encoded as binary proto or JSON.
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request, method protoreflect.MethodDescriptor, contentType string) {
+	ctx := r.Context()
+
+	if timeout := r.Header.Get("Connect-Timeout-Ms"); timeout != "" {
+		ms, err := strconv.ParseInt(timeout, 10, 64)
+		if err != nil || ms <= 0 {
+			writeConnectError(w, status.Errorf(codes.InvalidArgument, "invalid Connect-Timeout-Ms %q", timeout))
+			return
+		}
+
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+		defer cancel()
+	}
+
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		writeConnectError(w, status.Errorf(codes.Unimplemented, "content encoding %q isn't supported", encoding))
+		return
+	}
+
+	in, err := readBody(r.Body)
+	if err != nil {
+		writeConnectError(w, err)
+		return
+	}
+
+	isJSON := contentType == connectJSONContentType
+	if isJSON {
+		if in, err = transcode(in, method.Input(), protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal, proto.Marshal); err != nil {
+			writeConnectError(w, status.Errorf(codes.InvalidArgument, "invalid request: %s", err))
+			return
+		}
+	}
+
+	out, header, trailer, err := p.invoke(ctx, r, method, in)
+	if err != nil {
+		writeMetadata(w.Header(), header, "")
+		writeMetadata(w.Header(), trailer, "")
+		writeConnectError(w, err)
+		return
+	}
+
+	if isJSON {
+		if out, err = transcode(out, method.Output(), proto.Unmarshal, protojson.Marshal); err != nil {
+			writeConnectError(w, status.Errorf(codes.Internal, "invalid response: %s", err))
+			return
+		}
+	}
+
+	writeMetadata(w.Header(), header, "")
+	writeMetadata(w.Header(), trailer, "Trailer-")
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(out)
+}
+
+// transcode decodes b as a message of desc, and re-encodes it.
+func transcode(b []byte, desc protoreflect.MessageDescriptor, unmarshal func([]byte, proto.Message) error, marshal func(proto.Message) ([]byte, error)) ([]byte, error) {
+	msg := dynamicpb.NewMessage(desc)
+	if err := unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+
+	return marshal(msg)
+}
+
+func writeConnectError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	body := connectError{Code: connectCode(st.Code()), Message: st.Message()}
+	for _, detail := range st.Proto().GetDetails() {
+		body.Details = append(body.Details, connectDetail{
+			Type:  strings.TrimPrefix(detail.GetTypeUrl(), "type.googleapis.com/"),
+			Value: base64.RawStdEncoding.EncodeToString(detail.GetValue()),
+		})
+	}
+
+	httpStatus, ok := connectHTTPStatus[st.Code()]
+	if !ok {
+		httpStatus = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", connectJSONContentType)
+	w.WriteHeader(httpStatus)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// connectCode returns the Connect name of a code, e.g. invalid_argument.
+func connectCode(code codes.Code) string {
+	var b strings.Builder
+	for i, r := range code.String() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package webrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// gRPC-Web content types. The text variants base64 encode the body, for
+// clients that can't handle binary responses.
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+)
+
+// Flags of a gRPC-Web frame.
+const (
+	frameCompressed = 0x01
+	frameTrailer    = 0x80
+)
+
+// serveGRPCWeb serves a gRPC-Web request. As in gRPC, the response is always
+// 200 OK, with the RPC's status in a trailer frame at the end of the body.
+func (p *Proxy) serveGRPCWeb(w http.ResponseWriter, r *http.Request, method protoreflect.MethodDescriptor, contentType string) {
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+
+	base := grpcWebContentType
+	if text {
+		base = grpcWebTextContentType
+	}
+	if codec := strings.TrimPrefix(contentType, base); codec != "" && codec != "+proto" {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var (
+		out             []byte
+		header, trailer metadata.MD
+	)
+
+	in, err := readGRPCWebRequest(r, text)
+	if err == nil {
+		out, header, trailer, err = p.invoke(r.Context(), r, method, in)
+	}
+
+	var body bytes.Buffer
+	if err == nil {
+		writeFrame(&body, 0, out)
+	}
+	writeFrame(&body, frameTrailer, grpcWebTrailer(err, trailer))
+
+	writeMetadata(w.Header(), header, "")
+	w.Header().Set("Content-Type", base+"+proto")
+
+	if text {
+		_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(body.Bytes())))
+		return
+	}
+
+	_, _ = w.Write(body.Bytes())
+}
+
+// readGRPCWebRequest reads the single, uncompressed message framed in the body.
+func readGRPCWebRequest(r *http.Request, text bool) ([]byte, error) {
+	body, err := readBody(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if text {
+		if body, err = base64.StdEncoding.DecodeString(string(body)); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid base64 body: %s", err)
+		}
+	}
+
+	if len(body) < 5 {
+		return nil, status.Error(codes.InvalidArgument, "missing request message")
+	}
+
+	flags, length := body[0], binary.BigEndian.Uint32(body[1:5])
+
+	switch {
+	case flags&frameCompressed != 0:
+		return nil, status.Error(codes.Unimplemented, "compressed messages aren't supported")
+	case flags != 0:
+		return nil, status.Errorf(codes.InvalidArgument, "unexpected frame flags %#x", flags)
+	case uint64(length) != uint64(len(body)-5):
+		return nil, status.Error(codes.InvalidArgument, "request must hold a single message")
+	}
+
+	return body[5:], nil
+}
+
+func writeFrame(buf *bytes.Buffer, flags byte, data []byte) {
+	var prefix [5]byte
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+
+	buf.Write(prefix[:])
+	buf.Write(data)
+}
+
+// grpcWebTrailer encodes the status of err and the trailer metadata as an
+// HTTP/1 header block, as gRPC-Web trailer frames hold.
+func grpcWebTrailer(err error, trailer metadata.MD) []byte {
+	st := status.Convert(err)
+
+	h := http.Header{}
+	h.Set("grpc-status", fmt.Sprint(int(st.Code())))
+	if st.Message() != "" {
+		h.Set("grpc-message", url.PathEscape(st.Message()))
+	}
+	if len(st.Details()) != 0 {
+		if b, err := proto.Marshal(st.Proto()); err == nil {
+			h.Set("grpc-status-details-bin", base64.RawStdEncoding.EncodeToString(b))
+		}
+	}
+	writeMetadata(h, trailer, "")
+
+	var buf bytes.Buffer
+	for key, values := range h {
+		for _, value := range values {
+			// gRPC-Web clients expect lower case keys.
+			fmt.Fprintf(&buf, "%s: %s\r\n", strings.ToLower(key), value)
+		}
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,135 @@
+// Package webrpc serves gRPC-Web and Connect protocol requests by proxying them
+// to a gRPC backend, so browsers can call RPCs without the JSON gateway.
+package webrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxMessageSize caps request messages, matching gRPC's default receive limit.
+const maxMessageSize = 4 << 20
+
+// Proxy serves the unary RPCs of a set of services over gRPC-Web and the
+// Connect protocol. Streaming RPCs aren't proxied.
+type Proxy struct {
+	mux     *runtime.ServeMux
+	conn    grpc.ClientConnInterface
+	methods map[string]protoreflect.MethodDescriptor
+}
+
+// NewProxy returns a proxy calling the RPCs of services on conn. Calls are
+// annotated by the gateway's mux, so they carry the same metadata (such as the
+// caller's Authorization header and request ID) as calls from the gateway.
+func NewProxy(mux *runtime.ServeMux, conn grpc.ClientConnInterface, services ...protoreflect.ServiceDescriptor) *Proxy {
+	methods := make(map[string]protoreflect.MethodDescriptor)
+	for _, service := range services {
+		for i := 0; i < service.Methods().Len(); i++ {
+			method := service.Methods().Get(i)
+			methods["/"+string(service.FullName())+"/"+string(method.Name())] = method
+		}
+	}
+
+	return &Proxy{mux: mux, conn: conn, methods: methods}
+}
+
+// Middleware serves requests for the proxied RPCs, at /<service>/<method>, and
+// passes any other request on to next.
+func (p *Proxy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, ok := p.methods[r.URL.Path]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		switch {
+		case r.Method != http.MethodPost:
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		case strings.HasPrefix(contentType, grpcWebContentType):
+			p.serveGRPCWeb(w, r, method, contentType)
+		case contentType == connectProtoContentType || contentType == connectJSONContentType:
+			p.serveConnect(w, r, method, contentType)
+		default:
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		}
+	})
+}
+
+// invoke calls method with the encoded request, returning the encoded response
+// and the metadata the backend sent.
+func (p *Proxy) invoke(ctx context.Context, r *http.Request, method protoreflect.MethodDescriptor, in []byte) (out []byte, header, trailer metadata.MD, err error) {
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, nil, nil, status.Error(codes.Unimplemented, "streaming RPCs aren't supported")
+	}
+
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+
+	ctx, err = runtime.AnnotateContext(ctx, p.mux, r, fullMethod)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = p.conn.Invoke(ctx, fullMethod, &in, &out,
+		grpc.ForceCodec(rawCodec{}), grpc.Header(&header), grpc.Trailer(&trailer))
+
+	return out, header, trailer, err
+}
+
+// readBody reads up to maxMessageSize bytes of the request body.
+func readBody(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed reading request: %s", err)
+	}
+	if len(b) > maxMessageSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "request larger than %d bytes", maxMessageSize)
+	}
+
+	return b, nil
+}
+
+// writeMetadata adds md to h. Binary values are base64 encoded, as in gRPC's
+// own HTTP/2 encoding.
+func writeMetadata(h http.Header, md metadata.MD, prefix string) {
+	for key, values := range md {
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.RawStdEncoding.EncodeToString([]byte(value))
+			}
+
+			h.Add(prefix+key, value)
+		}
+	}
+}
+
+// rawCodec passes already encoded messages through to and from the backend.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
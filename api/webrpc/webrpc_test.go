@@ -0,0 +1,338 @@
+package webrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	racinggw "git.neds.sh/matty/entain/proto/gateway/racing"
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// racingServer serves race 1, and records the metadata of the last call.
+type racingServer struct {
+	racing.UnimplementedRacingServer
+	md metadata.MD
+}
+
+func (s *racingServer) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+
+	var races []*racing.Race
+	for _, id := range in.GetFilter().GetMeetingIds() {
+		races = append(races, &racing.Race{Id: id, MeetingId: id, Name: "Race"})
+	}
+
+	return &racing.ListRacesResponse{Races: races}, nil
+}
+
+func (s *racingServer) GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.Race, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("x-served-by", "test"))
+
+	if in.Id != 1 {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.Id)
+	}
+
+	return &racing.Race{Id: 1, Name: "Race"}, nil
+}
+
+// newTestServer serves the proxy in front of the gateway, both calling an
+// in-process racing server.
+func newTestServer(t *testing.T) (*httptest.Server, *racingServer) {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	backend := &racingServer{}
+
+	grpcServer := grpc.NewServer()
+	racing.RegisterRacingServer(grpcServer, backend)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	mux := runtime.NewServeMux()
+	if err := racinggw.RegisterRacingHandler(context.Background(), mux, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewProxy(mux, conn, racing.File_racing_racing_proto.Services().ByName("Racing"))
+
+	server := httptest.NewServer(proxy.Middleware(mux))
+	t.Cleanup(server.Close)
+
+	return server, backend
+}
+
+func TestProxy_grpcWeb(t *testing.T) {
+	server, backend := newTestServer(t)
+
+	tests := []struct {
+		name        string
+		text        bool
+		method      string
+		in          proto.Message
+		out         proto.Message
+		wantStatus  string
+		wantMessage string
+		wantTrailer string
+	}{
+		{
+			name:       "binary",
+			method:     "ListRaces",
+			in:         &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{7}}},
+			out:        &racing.ListRacesResponse{Races: []*racing.Race{{Id: 7, MeetingId: 7, Name: "Race"}}},
+			wantStatus: "0",
+		},
+		{
+			name:        "text",
+			text:        true,
+			method:      "GetRace",
+			in:          &racing.GetRaceRequest{Id: 1},
+			out:         &racing.Race{Id: 1, Name: "Race"},
+			wantStatus:  "0",
+			wantTrailer: "test",
+		},
+		{
+			name:        "error",
+			method:      "GetRace",
+			in:          &racing.GetRaceRequest{Id: 2},
+			wantStatus:  "5",
+			wantMessage: "race%202%20not%20found",
+			wantTrailer: "test",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := proto.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var body bytes.Buffer
+			writeFrame(&body, 0, msg)
+
+			contentType := "application/grpc-web+proto"
+			reqBody := body.Bytes()
+			if tt.text {
+				contentType = "application/grpc-web-text"
+				reqBody = []byte(base64.StdEncoding.EncodeToString(reqBody))
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/racing.Racing/"+tt.method, bytes.NewReader(reqBody))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", "Bearer token")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			respBody, _ := ioutil.ReadAll(resp.Body)
+			if tt.text {
+				if respBody, err = base64.StdEncoding.DecodeString(string(respBody)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+
+			message, trailer := readFrames(t, respBody)
+
+			if tt.out != nil {
+				got := tt.out.ProtoReflect().New().Interface()
+				if err := proto.Unmarshal(message, got); err != nil || !proto.Equal(got, tt.out) {
+					t.Errorf("response = %v (%v), want %v", got, err, tt.out)
+				}
+			}
+
+			if got := trailer["grpc-status"]; got != tt.wantStatus {
+				t.Errorf("grpc-status = %q, want %q", got, tt.wantStatus)
+			}
+			if got := trailer["grpc-message"]; got != tt.wantMessage {
+				t.Errorf("grpc-message = %q, want %q", got, tt.wantMessage)
+			}
+			if got := trailer["x-served-by"]; got != tt.wantTrailer {
+				t.Errorf("x-served-by trailer = %q, want %q", got, tt.wantTrailer)
+			}
+
+			if got := backend.md.Get("authorization"); len(got) != 1 || got[0] != "Bearer token" {
+				t.Errorf("backend authorization = %v, want the caller's", got)
+			}
+		})
+	}
+}
+
+func TestProxy_connect(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	raceProto, _ := proto.Marshal(&racing.Race{Id: 1, Name: "Race"})
+	getProto, _ := proto.Marshal(&racing.GetRaceRequest{Id: 1})
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+		wantTrailer string
+	}{
+		{
+			name:        "proto",
+			method:      "GetRace",
+			contentType: "application/proto",
+			body:        string(getProto),
+			wantStatus:  http.StatusOK,
+			wantBody:    string(raceProto),
+			wantTrailer: "test",
+		},
+		{
+			name:        "json",
+			method:      "ListRaces",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"filter":{"meetingIds":["3"]},"ignored":true}`,
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"races":[{"id":"3","meetingId":"3","name":"Race"}]}`,
+		},
+		{
+			name:        "error",
+			method:      "GetRace",
+			contentType: "application/json",
+			body:        `{"id":"2"}`,
+			wantStatus:  http.StatusNotFound,
+			wantBody:    `{"code":"not_found","message":"race 2 not found"}`,
+		},
+		{
+			name:        "invalid json",
+			method:      "GetRace",
+			contentType: "application/json",
+			body:        `{"id":`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported content type",
+			method:      "GetRace",
+			contentType: "text/plain",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/racing.Racing/"+tt.method, tt.contentType, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantBody != "" && compactJSON(body) != compactJSON([]byte(tt.wantBody)) {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if got := resp.Header.Get("Trailer-X-Served-By"); got != tt.wantTrailer {
+				t.Errorf("Trailer-X-Served-By = %q, want %q", got, tt.wantTrailer)
+			}
+		})
+	}
+}
+
+func TestProxy_passesOn(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/v1/races/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("gateway route status = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/racing.Racing/GetRace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET of an RPC status = %d, want 405", resp.StatusCode)
+	}
+}
+
+// readFrames returns the message and parsed trailer of a gRPC-Web response.
+func readFrames(t *testing.T, body []byte) ([]byte, map[string]string) {
+	t.Helper()
+
+	var (
+		message []byte
+		trailer = make(map[string]string)
+	)
+
+	for len(body) > 0 {
+		if len(body) < 5 {
+			t.Fatalf("truncated frame %q", body)
+		}
+
+		flags, length := body[0], binary.BigEndian.Uint32(body[1:5])
+		data := body[5 : 5+length]
+		body = body[5+length:]
+
+		if flags&frameTrailer == 0 {
+			message = data
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\r\n") {
+			parts := strings.SplitN(line, ": ", 2)
+			trailer[parts[0]] = parts[1]
+		}
+	}
+
+	return message, trailer
+}
+
+// compactJSON strips the insignificant whitespace from JSON, leaving anything
+// else untouched.
+func compactJSON(b []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return string(b)
+	}
+
+	return buf.String()
+}
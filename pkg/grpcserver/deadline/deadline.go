@@ -0,0 +1,136 @@
+// Package deadline bounds how long RPCs may run, giving calls without a
+// deadline a default one and cutting overly long ones short.
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Timeouts bounds the deadline of an RPC. Zero leaves a bound unset.
+type Timeouts struct {
+	// Default is the timeout given to calls without a deadline.
+	Default time.Duration
+	// Max is the longest timeout a call may have. Longer deadlines are
+	// shortened to it.
+	Max time.Duration
+}
+
+// Options configures the interceptors.
+type Options struct {
+	// Timeouts applies to every method without its own.
+	Timeouts
+	// Methods maps full method names, e.g. "/racing.Racing/ListRaces", to
+	// their own timeouts. Bounds they leave unset fall back to Timeouts.
+	Methods map[string]Timeouts
+}
+
+// ParseMethodTimeouts parses per-method timeouts in the form
+// "<method>=<default>[/<max>],...", e.g. "/racing.Racing/GetRaceStats=10s/30s".
+func ParseMethodTimeouts(s string) (map[string]Timeouts, error) {
+	methods := make(map[string]Timeouts)
+
+	if s == "" {
+		return methods, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") {
+			return nil, fmt.Errorf("method timeout %q: want <method>=<default>[/<max>]", entry)
+		}
+
+		var (
+			t         Timeouts
+			durations = strings.SplitN(parts[1], "/", 2)
+			err       error
+		)
+
+		if t.Default, err = time.ParseDuration(durations[0]); err != nil || t.Default <= 0 {
+			return nil, fmt.Errorf("method timeout %q: default must be a positive duration", entry)
+		}
+		if len(durations) == 2 {
+			if t.Max, err = time.ParseDuration(durations[1]); err != nil || t.Max < t.Default {
+				return nil, fmt.Errorf("method timeout %q: max must be a duration of at least the default", entry)
+			}
+		}
+
+		methods[parts[0]] = t
+	}
+
+	return methods, nil
+}
+
+// UnaryServerInterceptor bounds the deadline of every unary RPC.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := opts.bound(ctx, info.FullMethod, true)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor bounds the deadline of every streaming RPC. Streams,
+// such as health watches, are often meant to stay open, so they're only given a
+// default deadline when their method has its own timeouts.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		_, configured := opts.Methods[info.FullMethod]
+
+		ctx, cancel := opts.bound(ss.Context(), info.FullMethod, configured)
+		defer cancel()
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// timeouts returns the timeouts of method.
+func (o Options) timeouts(method string) Timeouts {
+	t := o.Timeouts
+
+	if m, ok := o.Methods[method]; ok {
+		if m.Default > 0 {
+			t.Default = m.Default
+		}
+		if m.Max > 0 {
+			t.Max = m.Max
+		}
+	}
+
+	return t
+}
+
+// bound returns ctx with its deadline bounded by method's timeouts. Without a
+// deadline, ctx is only given one if withDefault is set.
+func (o Options) bound(ctx context.Context, method string, withDefault bool) (context.Context, context.CancelFunc) {
+	t := o.timeouts(method)
+	if t.Max > 0 && t.Default > t.Max {
+		t.Default = t.Max
+	}
+
+	deadline, ok := ctx.Deadline()
+
+	switch {
+	case !ok && !withDefault:
+	case !ok && t.Default > 0:
+		return context.WithTimeout(ctx, t.Default)
+	case !ok && t.Max > 0, ok && t.Max > 0 && time.Until(deadline) > t.Max:
+		return context.WithTimeout(ctx, t.Max)
+	}
+
+	return ctx, func() {}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
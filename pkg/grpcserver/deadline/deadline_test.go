@@ -0,0 +1,204 @@
+package deadline
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+func TestParseMethodTimeouts(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]Timeouts
+		wantErr bool
+	}{
+		{in: "", want: map[string]Timeouts{}},
+		{
+			in: "/racing.Racing/GetRaceStats=10s/30s,/racing.Racing/ListRaces=2s",
+			want: map[string]Timeouts{
+				"/racing.Racing/GetRaceStats": {Default: 10 * time.Second, Max: 30 * time.Second},
+				"/racing.Racing/ListRaces":    {Default: 2 * time.Second},
+			},
+		},
+		{in: "ListRaces=2s", wantErr: true},
+		{in: "/racing.Racing/ListRaces=soon", wantErr: true},
+		{in: "/racing.Racing/ListRaces=0s", wantErr: true},
+		{in: "/racing.Racing/ListRaces=10s/5s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMethodTimeouts(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMethodTimeouts(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseMethodTimeouts(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		for method, want := range tt.want {
+			if got[method] != want {
+				t.Errorf("ParseMethodTimeouts(%q)[%s] = %v, want %v", tt.in, method, got[method], want)
+			}
+		}
+	}
+}
+
+// deadlineServer records the time left before the deadline of the last call.
+type deadlineServer struct {
+	racing.UnimplementedRacingServer
+	healthpb.UnimplementedHealthServer
+
+	left time.Duration
+}
+
+func (s *deadlineServer) record(ctx context.Context) {
+	s.left = 0
+	if deadline, ok := ctx.Deadline(); ok {
+		s.left = time.Until(deadline)
+	}
+}
+
+func (s *deadlineServer) GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.Race, error) {
+	s.record(ctx)
+	return &racing.Race{Id: in.Id}, nil
+}
+
+func (s *deadlineServer) ListRaces(ctx context.Context, _ *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
+	s.record(ctx)
+	return &racing.ListRacesResponse{}, nil
+}
+
+func (s *deadlineServer) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	s.record(stream.Context())
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+func TestInterceptors(t *testing.T) {
+	opts := Options{
+		Timeouts: Timeouts{Default: 10 * time.Second, Max: 30 * time.Second},
+		Methods: map[string]Timeouts{
+			"/racing.Racing/ListRaces": {Default: 2 * time.Second},
+		},
+	}
+
+	backend := &deadlineServer{}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(opts)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(opts)),
+	)
+	racing.RegisterRacingServer(server, backend)
+	healthpb.RegisterHealthServer(server, backend)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := racing.NewRacingClient(conn)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		call    func(ctx context.Context) error
+		want    time.Duration
+	}{
+		{
+			name: "default",
+			call: func(ctx context.Context) error {
+				_, err := client.GetRace(ctx, &racing.GetRaceRequest{Id: 1})
+				return err
+			},
+			want: 10 * time.Second,
+		},
+		{
+			name:    "within max",
+			timeout: 20 * time.Second,
+			call: func(ctx context.Context) error {
+				_, err := client.GetRace(ctx, &racing.GetRaceRequest{Id: 1})
+				return err
+			},
+			want: 20 * time.Second,
+		},
+		{
+			name:    "over max",
+			timeout: time.Hour,
+			call: func(ctx context.Context) error {
+				_, err := client.GetRace(ctx, &racing.GetRaceRequest{Id: 1})
+				return err
+			},
+			want: 30 * time.Second,
+		},
+		{
+			name: "method default",
+			call: func(ctx context.Context) error {
+				_, err := client.ListRaces(ctx, &racing.ListRacesRequest{})
+				return err
+			},
+			want: 2 * time.Second,
+		},
+		{
+			name: "unconfigured stream",
+			call: func(ctx context.Context) error {
+				stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			want: 0,
+		},
+		{
+			name:    "stream over max",
+			timeout: time.Hour,
+			call: func(ctx context.Context) error {
+				stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			want: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			if err := tt.call(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			// Allow for the time taken to make the call.
+			if backend.left > tt.want || backend.left < tt.want-time.Second {
+				t.Errorf("time left = %v, want %v", backend.left, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Checker drives the gRPC health service. Services report SERVING only once
+// they've been marked ready and the database answers a ping, and fall back to
+// NOT_SERVING whenever the database becomes unreachable.
+type Checker struct {
+	server   *health.Server
+	db       *sql.DB
+	interval time.Duration
+	services []string
+	ready    int32
+}
+
+// NewChecker creates a health checker for the given services, pinging db every interval.
+// The overall server health (the empty service name) is always reported.
+func NewChecker(db *sql.DB, interval time.Duration, services ...string) *Checker {
+	c := &Checker{
+		server:   health.NewServer(),
+		db:       db,
+		interval: interval,
+		services: append([]string{""}, services...),
+	}
+
+	c.set(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	return c
+}
+
+// Server returns the grpc.health.v1.Health implementation to register.
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// SetReady marks initialisation as finished and runs a check straight away.
+func (c *Checker) SetReady(ctx context.Context) {
+	atomic.StoreInt32(&c.ready, 1)
+	c.check(ctx)
+}
+
+// Watch re-checks the database every interval until ctx is done.
+func (c *Checker) Watch(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.check(ctx)
+		}
+	}
+}
+
+// Shutdown reports every service as NOT_SERVING from now on, ahead of the
+// server draining its connections. Later checks no longer change the status.
+func (c *Checker) Shutdown() {
+	c.server.Shutdown()
+}
+
+func (c *Checker) check(ctx context.Context) {
+	if atomic.LoadInt32(&c.ready) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	if err := c.db.PingContext(ctx); err != nil {
+		c.set(healthpb.HealthCheckResponse_NOT_SERVING)
+		return
+	}
+
+	c.set(healthpb.HealthCheckResponse_SERVING)
+}
+
+func (c *Checker) set(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
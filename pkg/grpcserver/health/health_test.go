@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakeConnector hands out connections that can't run anything, which is
+// enough for pings to succeed.
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func TestChecker(t *testing.T) {
+	const service = "racing.Racing"
+
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+
+	c := NewChecker(db, time.Second, service)
+	ctx := context.Background()
+
+	steps := []struct {
+		name string
+		do   func()
+		want healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{name: "created", do: func() {}, want: healthpb.HealthCheckResponse_NOT_SERVING},
+		{name: "checked before ready", do: func() { c.check(ctx) }, want: healthpb.HealthCheckResponse_NOT_SERVING},
+		{name: "ready", do: func() { c.SetReady(ctx) }, want: healthpb.HealthCheckResponse_SERVING},
+		{name: "failed ping", do: func() { db.Close(); c.check(ctx) }, want: healthpb.HealthCheckResponse_NOT_SERVING},
+	}
+
+	for _, step := range steps {
+		step.do()
+
+		for _, name := range []string{"", service} {
+			resp, err := c.Server().Check(ctx, &healthpb.HealthCheckRequest{Service: name})
+			if err != nil {
+				t.Fatalf("%s: Check(%q) error = %v", step.name, name, err)
+			}
+			if resp.Status != step.want {
+				t.Errorf("%s: Check(%q) = %v, want %v", step.name, name, resp.Status, step.want)
+			}
+		}
+	}
+}
+
+func TestChecker_Shutdown(t *testing.T) {
+	const service = "racing.Racing"
+
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+
+	c := NewChecker(db, time.Second, service)
+	ctx := context.Background()
+
+	c.SetReady(ctx)
+	c.Shutdown()
+	// The database is still reachable, but draining servers stay NOT_SERVING.
+	c.check(ctx)
+
+	for _, name := range []string{"", service} {
+		resp, err := c.Server().Check(ctx, &healthpb.HealthCheckRequest{Service: name})
+		if err != nil {
+			t.Fatalf("Check(%q) error = %v", name, err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("Check(%q) after Shutdown() = %v, want NOT_SERVING", name, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the gRPC metadata key carrying the ID of the originating request.
+const RequestIDKey = "x-request-id"
+
+type loggerKey struct{}
+
+// NewLogger creates a JSON logger at the given level, e.g. "info" or "debug".
+func NewLogger(level string) (*logrus.Logger, error) {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(lvl)
+
+	return logger, nil
+}
+
+// FromContext returns the request-scoped logger stored in ctx, falling back to
+// the standard logger outside of a request.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// WithContext returns a copy of ctx carrying entry as its request-scoped logger.
+func WithContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, entry)
+}
+
+// NewRequestID generates a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// UnaryServerInterceptor attaches a request-scoped logger to each call and
+// writes one access log line per call once it completes. The request ID is
+// taken from incoming metadata when the caller supplied one.
+func UnaryServerInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		entry := callEntry(ctx, logger, info.FullMethod)
+
+		resp, err := handler(WithContext(ctx, entry), req)
+
+		logCompleted(entry, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor does for streaming RPCs what UnaryServerInterceptor
+// does for unary ones, logging once the stream ends.
+func StreamServerInterceptor(logger *logrus.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		entry := callEntry(ss.Context(), logger, info.FullMethod)
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: WithContext(ss.Context(), entry)})
+
+		logCompleted(entry, start, err)
+		return err
+	}
+}
+
+// LogPanic logs a panic recovered from a call, with its stack. It has the
+// signature of a recovery.Handler.
+func LogPanic(ctx context.Context, method string, p interface{}, stack []byte) {
+	FromContext(ctx).WithFields(logrus.Fields{
+		"grpc_method": method,
+		"stack":       string(stack),
+	}).Errorf("recovered from panic: %v", p)
+}
+
+// callEntry returns the logger for a call to method.
+func callEntry(ctx context.Context, logger *logrus.Logger, method string) *logrus.Entry {
+	entry := logger.WithFields(logrus.Fields{
+		"request_id":  requestID(ctx),
+		"grpc_method": method,
+	})
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		entry = entry.WithField("trace_id", spanCtx.TraceID().String())
+	}
+
+	return entry
+}
+
+// logCompleted writes the access log line of a call that started at start.
+func logCompleted(entry *logrus.Entry, start time.Time, err error) {
+	entry = entry.WithFields(logrus.Fields{
+		"grpc_code":  status.Code(err).String(),
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+	})
+	if err != nil {
+		entry.WithError(err).Warn("request failed")
+	} else {
+		entry.Info("request completed")
+	}
+}
+
+// requestID returns the request ID from incoming metadata, or a new one.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDKey); len(ids) != 0 && ids[0] != "" {
+			return ids[0]
+		}
+	}
+
+	return NewRequestID()
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServerStream is a server stream carrying only a context.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestInterceptors(t *testing.T) {
+	const method = "/racing.Racing/GetRace"
+
+	tests := []struct {
+		name          string
+		stream        bool
+		requestID     string
+		err           error
+		wantCode      string
+		wantLevel     logrus.Level
+		wantRequestID bool
+	}{
+		{name: "unary", requestID: "abc123", wantCode: "OK", wantLevel: logrus.InfoLevel, wantRequestID: true},
+		{name: "unary failed", requestID: "abc123", err: status.Error(codes.NotFound, "no race"), wantCode: "NotFound", wantLevel: logrus.WarnLevel, wantRequestID: true},
+		{name: "unary without request id", wantCode: "OK", wantLevel: logrus.InfoLevel},
+		{name: "stream", stream: true, requestID: "abc123", wantCode: "OK", wantLevel: logrus.InfoLevel, wantRequestID: true},
+		{name: "stream failed", stream: true, err: status.Error(codes.Internal, "boom"), wantCode: "Internal", wantLevel: logrus.WarnLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := logrustest.NewNullLogger()
+
+			ctx := context.Background()
+			if tt.requestID != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(RequestIDKey, tt.requestID))
+			}
+
+			// The handler's logger carries the same request ID as the access log.
+			var handlerRequestID interface{}
+			handle := func(ctx context.Context) error {
+				handlerRequestID = FromContext(ctx).Data["request_id"]
+				return tt.err
+			}
+
+			if tt.stream {
+				_ = StreamServerInterceptor(logger)(nil, &fakeServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method}, func(_ interface{}, ss grpc.ServerStream) error {
+					return handle(ss.Context())
+				})
+			} else {
+				_, _ = UnaryServerInterceptor(logger)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+					return nil, handle(ctx)
+				})
+			}
+
+			if len(hook.Entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(hook.Entries))
+			}
+			entry := hook.LastEntry()
+
+			if entry.Level != tt.wantLevel {
+				t.Errorf("level = %v, want %v", entry.Level, tt.wantLevel)
+			}
+			if entry.Data["grpc_method"] != method || entry.Data["grpc_code"] != tt.wantCode {
+				t.Errorf("grpc_method, grpc_code = %v, %v, want %s, %s", entry.Data["grpc_method"], entry.Data["grpc_code"], method, tt.wantCode)
+			}
+			if _, ok := entry.Data["latency_ms"].(float64); !ok {
+				t.Errorf("latency_ms = %v, want a number", entry.Data["latency_ms"])
+			}
+
+			requestID, _ := entry.Data["request_id"].(string)
+			if requestID == "" || (tt.wantRequestID && requestID != tt.requestID) {
+				t.Errorf("request_id = %q, want the caller's %q or a new one", requestID, tt.requestID)
+			}
+			if handlerRequestID != requestID {
+				t.Errorf("handler's request_id = %v, want %s", handlerRequestID, requestID)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	rpcHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server, by method and status code.",
+	}, []string{"grpc_method", "grpc_code"})
+
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Latency of RPCs handled by the server, by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_method", "grpc_code"})
+
+	rpcPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_panics_total",
+		Help: "Total number of panics recovered from while handling RPCs, by method.",
+	}, []string{"grpc_method"})
+)
+
+// Handler serves the metrics in the default registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// UnaryServerInterceptor records a count and latency observation for every unary RPC.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		observe(info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records a count and latency observation for every
+// streaming RPC, timed until the stream ends.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		err := handler(srv, ss)
+
+		observe(info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+// RecordPanic counts a panic recovered from while handling method.
+func RecordPanic(method string) {
+	rpcPanics.WithLabelValues(method).Inc()
+}
+
+// RegisterDBStats exposes the connection pool statistics of db as gauges,
+// labelled with the given database name.
+func RegisterDBStats(name string, db *sql.DB) {
+	labels := prometheus.Labels{"db_name": name}
+
+	gauge := func(metric, help string, value func(sql.DBStats) float64) {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "db_pool_" + metric,
+			Help:        help,
+			ConstLabels: labels,
+		}, func() float64 {
+			return value(db.Stats())
+		})
+	}
+
+	gauge("max_open_connections", "Maximum number of open connections to the database.", func(s sql.DBStats) float64 {
+		return float64(s.MaxOpenConnections)
+	})
+	gauge("open_connections", "Number of established connections, both in use and idle.", func(s sql.DBStats) float64 {
+		return float64(s.OpenConnections)
+	})
+	gauge("in_use_connections", "Number of connections currently in use.", func(s sql.DBStats) float64 {
+		return float64(s.InUse)
+	})
+	gauge("idle_connections", "Number of idle connections.", func(s sql.DBStats) float64 {
+		return float64(s.Idle)
+	})
+
+	counter := func(metric, help string, value func(sql.DBStats) float64) {
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "db_pool_" + metric,
+			Help:        help,
+			ConstLabels: labels,
+		}, func() float64 {
+			return value(db.Stats())
+		})
+	}
+
+	counter("wait_count_total", "Total number of connections waited for.", func(s sql.DBStats) float64 {
+		return float64(s.WaitCount)
+	})
+	counter("wait_duration_seconds_total", "Total time blocked waiting for a new connection.", func(s sql.DBStats) float64 {
+		return s.WaitDuration.Seconds()
+	})
+}
+
+// RegisterCounterFunc exposes a monotonically increasing value read from value on every scrape.
+func RegisterCounterFunc(name, help string, value func() float64) {
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}, value)
+}
+
+// observe records an RPC to method started at start, labelled with err's code.
+func observe(method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	rpcHandled.WithLabelValues(method, code).Inc()
+	rpcDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
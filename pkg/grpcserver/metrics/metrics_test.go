@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServerStream is a server stream carrying only a context.
+type fakeServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func TestInterceptors(t *testing.T) {
+	// Each case uses its own method, as the metrics are shared by every test.
+	tests := []struct {
+		name     string
+		method   string
+		stream   bool
+		err      error
+		wantCode string
+	}{
+		{name: "unary", method: "/test.Test/Unary", wantCode: "OK"},
+		{name: "unary failed", method: "/test.Test/UnaryFailed", err: status.Error(codes.NotFound, "no race"), wantCode: "NotFound"},
+		{name: "stream", method: "/test.Test/Stream", stream: true, wantCode: "OK"},
+		{name: "stream failed", method: "/test.Test/StreamFailed", stream: true, err: errors.New("boom"), wantCode: "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				if tt.stream {
+					_ = StreamServerInterceptor()(nil, &fakeServerStream{}, &grpc.StreamServerInfo{FullMethod: tt.method}, func(interface{}, grpc.ServerStream) error {
+						return tt.err
+					})
+				} else {
+					_, _ = UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(context.Context, interface{}) (interface{}, error) {
+						return nil, tt.err
+					})
+				}
+			}
+
+			if got := testutil.ToFloat64(rpcHandled.WithLabelValues(tt.method, tt.wantCode)); got != 2 {
+				t.Errorf("grpc_server_handled_total = %v, want 2", got)
+			}
+			if got := sampleCount(t, rpcDuration.WithLabelValues(tt.method, tt.wantCode)); got != 2 {
+				t.Errorf("grpc_server_handling_seconds count = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestRecordPanic(t *testing.T) {
+	RecordPanic("/test.Test/Panic")
+
+	if got := testutil.ToFloat64(rpcPanics.WithLabelValues("/test.Test/Panic")); got != 1 {
+		t.Errorf("grpc_server_panics_total = %v, want 1", got)
+	}
+}
+
+// fakeConnector hands out connections that can't run anything, which is
+// enough to open and pool them.
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func TestRegisterDBStats(t *testing.T) {
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+	db.SetMaxOpenConns(3)
+
+	RegisterDBStats("test", db)
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{name: "db_pool_max_open_connections", want: 3},
+		{name: "db_pool_open_connections", want: 1},
+		{name: "db_pool_in_use_connections", want: 1},
+		{name: "db_pool_idle_connections", want: 0},
+		{name: "db_pool_wait_count_total", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := value(t, tt.name); !ok || got != tt.want {
+				t.Errorf("%s = %v (found %v), want %v", tt.name, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+// sampleCount returns the number of observations made by a histogram.
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetHistogram().GetSampleCount()
+}
+
+// value returns the value of the gauge or counter with the given name in the
+// default registry, reporting whether it was found.
+func value(t *testing.T, name string) (float64, bool) {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var metrics []*dto.Metric
+	for _, family := range families {
+		if family.GetName() == name {
+			metrics = append(metrics, family.GetMetric()...)
+		}
+	}
+	if len(metrics) == 0 {
+		return 0, false
+	}
+
+	if gauge := metrics[0].GetGauge(); gauge != nil {
+		return gauge.GetValue(), true
+	}
+
+	return metrics[0].GetCounter().GetValue(), true
+}
@@ -0,0 +1,54 @@
+// Package recovery turns panics in RPC handlers into Internal errors, so one
+// bad request can't bring the server down.
+package recovery
+
+import (
+	"context"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Handler is called with every recovered panic, its value and the stack of the
+// panicking goroutine, e.g. to log or count it.
+type Handler func(ctx context.Context, method string, p interface{}, stack []byte)
+
+// UnaryServerInterceptor recovers from panics in unary RPCs, failing them with
+// Internal after calling handlers.
+func UnaryServerInterceptor(handlers ...Handler) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, info.FullMethod, p, handlers)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor recovers from panics in streaming RPCs, failing them
+// with Internal after calling handlers.
+func StreamServerInterceptor(handlers ...Handler) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ss.Context(), info.FullMethod, p, handlers)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, p interface{}, handlers []Handler) error {
+	stack := debug.Stack()
+	for _, h := range handlers {
+		h(ctx, method, p, stack)
+	}
+
+	// The panic value may hold internals, so callers only learn that it happened.
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,88 @@
+package recovery
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// panickingServer panics serving race 0, and any health watch.
+type panickingServer struct {
+	racing.UnimplementedRacingServer
+	healthpb.UnimplementedHealthServer
+}
+
+func (s *panickingServer) GetRace(_ context.Context, in *racing.GetRaceRequest) (*racing.Race, error) {
+	if in.Id == 0 {
+		var race *racing.Race
+		return &racing.Race{Name: race.Name}, nil
+	}
+
+	return &racing.Race{Id: in.Id}, nil
+}
+
+func (s *panickingServer) Watch(*healthpb.HealthCheckRequest, healthpb.Health_WatchServer) error {
+	panic("watch")
+}
+
+func TestInterceptors(t *testing.T) {
+	var panics []string
+	onPanic := func(_ context.Context, method string, p interface{}, stack []byte) {
+		if len(stack) == 0 {
+			t.Errorf("panic in %s recovered without a stack", method)
+		}
+		panics = append(panics, method)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(onPanic)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(onPanic)),
+	)
+	racing.RegisterRacingServer(server, &panickingServer{})
+	healthpb.RegisterHealthServer(server, &panickingServer{})
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	client := racing.NewRacingClient(conn)
+
+	if _, err := client.GetRace(ctx, &racing.GetRaceRequest{Id: 0}); status.Code(err) != codes.Internal {
+		t.Errorf("panicking GetRace error = %v, want Internal", err)
+	}
+
+	// The server survives to serve the next call.
+	if race, err := client.GetRace(ctx, &racing.GetRaceRequest{Id: 1}); err != nil || race.Id != 1 {
+		t.Errorf("GetRace() after a panic = %v, %v, want race 1", race, err)
+	}
+
+	watch, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watch.Recv(); status.Code(err) != codes.Internal {
+		t.Errorf("panicking Watch error = %v, want Internal", err)
+	}
+
+	want := []string{"/racing.Racing/GetRace", "/grpc.health.v1.Health/Watch"}
+	if len(panics) != len(want) || panics[0] != want[0] || panics[1] != want[1] {
+		t.Errorf("handled panics in %v, want %v", panics, want)
+	}
+}
@@ -0,0 +1,68 @@
+package validation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        interface{}
+		wantCode   codes.Code
+		wantFields []string
+	}{
+		{
+			name: "valid",
+			req:  &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1}}, PageSize: 10},
+		},
+		{
+			name:       "invalid",
+			req:        &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{-1}}, PageSize: 5000},
+			wantCode:   codes.InvalidArgument,
+			wantFields: []string{"filter.meeting_ids[0]", "page_size"},
+		},
+		{
+			name: "not a message",
+			req:  "ping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			}
+
+			_, err := UnaryServerInterceptor()(context.Background(), tt.req, &grpc.UnaryServerInfo{FullMethod: "/racing.Racing/ListRaces"}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("interceptor code = %s, want %s (%v)", code, tt.wantCode, err)
+			}
+			if called != (tt.wantCode == codes.OK) {
+				t.Errorf("handler called = %v, want %v", called, tt.wantCode == codes.OK)
+			}
+
+			var fields []string
+			for _, detail := range status.Convert(err).Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, violation := range badRequest.FieldViolations {
+						fields = append(fields, violation.Field)
+					}
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("field violations = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
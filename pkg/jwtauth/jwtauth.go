@@ -0,0 +1,146 @@
+// Package jwtauth verifies the JWT bearer tokens callers identify themselves
+// with.
+package jwtauth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Verifier validates JWTs signed with HS256 using a shared secret, or with
+// RS256 using the public keys of a JWKS.
+type Verifier struct {
+	secret []byte
+	keys   map[string]*rsa.PublicKey
+}
+
+// NewVerifier creates a verifier accepting HS256 tokens signed with secret
+// (when set) and RS256 tokens signed by one of keys (when set).
+func NewVerifier(secret []byte, keys map[string]*rsa.PublicKey) *Verifier {
+	return &Verifier{secret: secret, keys: keys}
+}
+
+// Claims are the verified claims of a token.
+type Claims struct {
+	Subject string
+	Roles   []string
+}
+
+// TokenClaims are the JWT claims we read from tokens.
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+// Verify checks the signature and expiry of token, returning its claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	var claims TokenClaims
+
+	parser := &jwt.Parser{ValidMethods: v.validMethods()}
+
+	if _, err := parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return nil, err
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return &Claims{Subject: claims.Subject, Roles: claims.Roles}, nil
+}
+
+func (v *Verifier) validMethods() []string {
+	var methods []string
+
+	if len(v.secret) != 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(v.keys) != 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	return methods
+}
+
+// key returns the key to check the signature of token with.
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method {
+	case jwt.SigningMethodHS256:
+		return v.secret, nil
+	case jwt.SigningMethodRS256:
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := v.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing method %s", token.Method.Alg())
+	}
+}
+
+// jwks is a JSON Web Key Set, as described in RFC 7517.
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// LoadJWKS reads the RSA signing keys from a JWKS file, by key ID. Keys of
+// other types, or not meant for signatures, are ignored.
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("failed parsing %s: %w", path, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid modulus: %w", k.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid exponent: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA signing keys found in %s", path)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,87 @@
+package jwtauth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+	"git.neds.sh/matty/entain/pkg/jwtauth/jwtauthtest"
+)
+
+var secret = []byte("test-secret")
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := jwtauth.LoadJWKS(jwtauthtest.WriteJWKS(t, "key-1", &rsaKey.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := jwtauth.NewVerifier(secret, keys)
+	expiry := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "hs256",
+			token: jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "alice", expiry, "trader"),
+		},
+		{
+			name:  "rs256",
+			token: jwtauthtest.Sign(t, jwt.SigningMethodRS256, rsaKey, "key-1", "alice", expiry, "trader"),
+		},
+		{
+			name:    "wrong secret",
+			token:   jwtauthtest.Sign(t, jwt.SigningMethodHS256, []byte("other"), "", "alice", expiry),
+			wantErr: true,
+		},
+		{
+			name:    "unknown key id",
+			token:   jwtauthtest.Sign(t, jwt.SigningMethodRS256, rsaKey, "key-2", "alice", expiry),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported algorithm",
+			token:   jwtauthtest.Sign(t, jwt.SigningMethodHS512, secret, "", "alice", expiry),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "alice", time.Now().Add(-time.Minute)),
+			wantErr: true,
+		},
+		{
+			name:    "no expiry",
+			token:   jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "alice", time.Time{}),
+			wantErr: true,
+		},
+		{
+			name:    "no subject",
+			token:   jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "", expiry),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.Subject != "alice" || len(claims.Roles) != 1 || claims.Roles[0] != "trader") {
+				t.Errorf("Verify() = %+v, want alice with the trader role", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Package jwtauthtest signs tokens and writes key sets for tests of code
+// verifying them.
+package jwtauthtest
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+)
+
+// Sign returns a token for subject with roles, signed by key with method. The
+// token has no expiry when expiry is zero, and no kid header when kid is empty.
+func Sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid, subject string, expiry time.Time, roles ...string) string {
+	t.Helper()
+
+	claims := jwtauth.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+		Roles:            roles,
+	}
+	if !expiry.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(expiry)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+// WriteJWKS writes a JWKS holding key under kid to a temporary file, returning its path.
+func WriteJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
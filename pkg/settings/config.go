@@ -0,0 +1,91 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is a binary's configuration, made up of settings.
+type Config interface {
+	// Settings returns the settings of the config, each reading and writing its field.
+	Settings() []Setting
+	// Validate checks the config is usable, reporting every problem found.
+	Validate() error
+}
+
+// LoadConfig builds cfg, which holds the defaults beforehand, from an optional
+// YAML config file (--config), environment variables prefixed with envPrefix
+// and command line flags, in increasing order of precedence, and validates the
+// result. It also reports whether --print-config was given.
+func LoadConfig(args []string, envPrefix string, cfg Config) (bool, error) {
+	printConfig, err := Load(args, envPrefix, cfg.Settings())
+	if err != nil {
+		return false, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return false, err
+	}
+
+	return printConfig, nil
+}
+
+// Print writes cfg in the format accepted by --config, with secrets redacted.
+func Print(w io.Writer, cfg Config) error {
+	return Write(w, cfg.Settings())
+}
+
+// Problems collects everything wrong with a config, so it can all be reported
+// at once.
+type Problems struct {
+	errs []string
+}
+
+// Addf records a problem with the named setting.
+func (p *Problems) Addf(name, format string, args ...interface{}) {
+	p.errs = append(p.errs, name+": "+fmt.Sprintf(format, args...))
+}
+
+// Check records err, if any, as a problem with the named setting.
+func (p *Problems) Check(name string, err error) {
+	if err != nil {
+		p.Addf(name, "%s", err)
+	}
+}
+
+// Endpoint checks the named setting is a host:port.
+func (p *Problems) Endpoint(name, endpoint string) {
+	_, _, err := net.SplitHostPort(endpoint)
+	p.Check(name, err)
+}
+
+// Positive checks the named duration is above zero.
+func (p *Problems) Positive(name string, d time.Duration) {
+	if d <= 0 {
+		p.Addf(name, "must be positive")
+	}
+}
+
+// NotNegative checks the named duration isn't below zero.
+func (p *Problems) NotNegative(name string, d time.Duration) {
+	if d < 0 {
+		p.Addf(name, "must not be negative")
+	}
+}
+
+// Err returns the problems found as one error, in a stable order, or nil when
+// there are none.
+func (p *Problems) Err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+
+	sort.Strings(p.errs)
+
+	return errors.New("invalid config: " + strings.Join(p.errs, "; "))
+}
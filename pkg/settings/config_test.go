@@ -0,0 +1,95 @@
+package settings
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+type groupConfig struct {
+	ServerTLS
+	RPCTimeouts
+	Telemetry
+}
+
+func (c *groupConfig) Settings() []Setting {
+	s := append(c.ServerTLS.Settings(), c.RPCTimeouts.Settings()...)
+	return append(s, c.Telemetry.Settings()...)
+}
+
+func (c *groupConfig) Validate() error {
+	var p Problems
+	c.ServerTLS.Check(&p)
+	c.RPCTimeouts.Check(&p)
+	c.Telemetry.Check(&p)
+
+	return p.Err()
+}
+
+func newGroupConfig() *groupConfig {
+	return &groupConfig{RPCTimeouts: DefaultRPCTimeouts(), Telemetry: DefaultTelemetry()}
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg := newGroupConfig()
+	printConfig, err := LoadConfig([]string{"test", "--log-level", "debug", "--rpc-max-timeout", "1m", "--print-config"}, "TEST", cfg)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if !printConfig {
+		t.Errorf("LoadConfig() printConfig = false, want true")
+	}
+	if cfg.LogLevel != "debug" || cfg.RPCMaxTimeout != time.Minute {
+		t.Errorf("LoadConfig() = %+v, want log-level debug and rpc-max-timeout 1m", cfg)
+	}
+
+	var buf bytes.Buffer
+	if err := Print(&buf, cfg); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "log-level: \"debug\"\n") {
+		t.Errorf("Print() = %q, want it to contain log-level: debug", buf.String())
+	}
+}
+
+func TestLoadConfig_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "cert without key", args: []string{"--tls-cert-file", "cert.pem"}, wantErr: "tls-cert-file: must be set together with tls-key-file"},
+		{name: "client CA without cert", args: []string{"--tls-client-ca-file", "ca.pem"}, wantErr: "tls-client-ca-file: requires tls-cert-file"},
+		{name: "negative timeout", args: []string{"--rpc-default-timeout", "-1s"}, wantErr: "rpc-default-timeout: must not be negative"},
+		{name: "default above max", args: []string{"--rpc-default-timeout", "1m"}, wantErr: "rpc-default-timeout: must not exceed rpc-max-timeout"},
+		{name: "log level", args: []string{"--log-level", "loud"}, wantErr: "log-level: "},
+		{name: "trace exporter", args: []string{"--trace-exporter", "jaeger"}, wantErr: `trace-exporter: unknown exporter "jaeger"`},
+		{name: "otlp endpoint", args: []string{"--otlp-endpoint", "collector"}, wantErr: "otlp-endpoint: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(append([]string{"test"}, tt.args...), "TEST", newGroupConfig())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProblems_Err(t *testing.T) {
+	var p Problems
+	if err := p.Err(); err != nil {
+		t.Errorf("Err() with no problems = %v, want nil", err)
+	}
+
+	p.Positive("b", 0)
+	p.NotNegative("c", time.Second)
+	p.Addf("a", "bad %d", 1)
+
+	want := "invalid config: a: bad 1; b: must be positive"
+	if err := p.Err(); err == nil || err.Error() != want {
+		t.Errorf("Err() = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,103 @@
+package settings
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"git.neds.sh/matty/entain/pkg/tracing"
+)
+
+// The groups below hold settings several binaries share. Configs embed them,
+// so their fields read as the config's own, and add their settings and checks
+// to the config's.
+
+// ServerTLS holds the settings serving gRPC over TLS, and optionally mutual TLS.
+type ServerTLS struct {
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+}
+
+// Settings returns the settings of t.
+func (t *ServerTLS) Settings() []Setting {
+	return []Setting{
+		{Name: "tls-cert-file", Usage: "PEM certificate to serve gRPC over TLS with, reloaded when it changes", Value: String(&t.TLSCertFile)},
+		{Name: "tls-key-file", Usage: "PEM private key for tls-cert-file", Value: String(&t.TLSKeyFile)},
+		{Name: "tls-client-ca-file", Usage: "PEM CA bundle client certificates must be signed by, enabling mutual TLS", Value: String(&t.TLSClientCAFile)},
+	}
+}
+
+// Check records the problems with t.
+func (t *ServerTLS) Check(p *Problems) {
+	if (t.TLSCertFile == "") != (t.TLSKeyFile == "") {
+		p.Addf("tls-cert-file", "must be set together with tls-key-file")
+	}
+	if t.TLSClientCAFile != "" && t.TLSCertFile == "" {
+		p.Addf("tls-client-ca-file", "requires tls-cert-file")
+	}
+}
+
+// RPCTimeouts holds the deadlines a gRPC server gives the RPCs it serves.
+type RPCTimeouts struct {
+	RPCDefaultTimeout time.Duration
+	RPCMaxTimeout     time.Duration
+}
+
+// DefaultRPCTimeouts returns the RPC timeouts used when nothing is overridden.
+func DefaultRPCTimeouts() RPCTimeouts {
+	return RPCTimeouts{RPCDefaultTimeout: 10 * time.Second, RPCMaxTimeout: 30 * time.Second}
+}
+
+// Settings returns the settings of t.
+func (t *RPCTimeouts) Settings() []Setting {
+	return []Setting{
+		{Name: "rpc-default-timeout", Usage: "Timeout given to RPCs whose caller sets no deadline, or 0 for none", Value: Duration(&t.RPCDefaultTimeout)},
+		{Name: "rpc-max-timeout", Usage: "Longest timeout an RPC may run for, shortening longer deadlines, or 0 for no limit", Value: Duration(&t.RPCMaxTimeout)},
+	}
+}
+
+// Check records the problems with t.
+func (t *RPCTimeouts) Check(p *Problems) {
+	p.NotNegative("rpc-default-timeout", t.RPCDefaultTimeout)
+	p.NotNegative("rpc-max-timeout", t.RPCMaxTimeout)
+
+	if t.RPCMaxTimeout > 0 && t.RPCDefaultTimeout > t.RPCMaxTimeout {
+		p.Addf("rpc-default-timeout", "must not exceed rpc-max-timeout")
+	}
+}
+
+// Telemetry holds the logging and tracing settings.
+type Telemetry struct {
+	LogLevel      string
+	TraceExporter string
+	OTLPEndpoint  string
+}
+
+// DefaultTelemetry returns the telemetry used when nothing is overridden.
+func DefaultTelemetry() Telemetry {
+	return Telemetry{LogLevel: "info", TraceExporter: tracing.ExporterNone, OTLPEndpoint: "localhost:4317"}
+}
+
+// Settings returns the settings of t.
+func (t *Telemetry) Settings() []Setting {
+	return []Setting{
+		{Name: "log-level", Usage: "Minimum level to log at", Value: String(&t.LogLevel)},
+		{Name: "trace-exporter", Usage: "Trace exporter to use: none, stdout or otlp", Value: String(&t.TraceExporter)},
+		{Name: "otlp-endpoint", Usage: "OTLP collector endpoint, used by the otlp trace exporter", Value: String(&t.OTLPEndpoint)},
+	}
+}
+
+// Check records the problems with t.
+func (t *Telemetry) Check(p *Problems) {
+	_, err := logrus.ParseLevel(t.LogLevel)
+	p.Check("log-level", err)
+
+	switch t.TraceExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		p.Addf("trace-exporter", "unknown exporter %q", t.TraceExporter)
+	}
+
+	p.Endpoint("otlp-endpoint", t.OTLPEndpoint)
+}
@@ -0,0 +1,218 @@
+// Package settings loads a binary's configuration from defaults, a YAML config
+// file, environment variables and command line flags.
+package settings
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// redacted replaces the value of secret settings when printing the configuration.
+const redacted = "[REDACTED]"
+
+// usageOutput is where -h prints the settings and their defaults.
+var usageOutput io.Writer = os.Stderr
+
+// Setting describes a single configuration value. Each setting can be given
+// in the config file (under its name), as an environment variable (its name
+// upper-cased with the environment prefix) and as a command line flag.
+type Setting struct {
+	Name   string
+	Usage  string
+	Secret bool
+	Value  flag.Value
+}
+
+// Load applies, in increasing order of precedence, the config file named by
+// --config, environment variables and command line flags to settings, whose
+// values hold the defaults beforehand. It also reports whether --print-config
+// was given.
+func Load(args []string, envPrefix string, settings []Setting) (bool, error) {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(usageOutput)
+	configPath := fs.String("config", "", "Path to a YAML config file")
+	printConfig := fs.Bool("print-config", false, "Print the effective configuration and exit")
+
+	byName := make(map[string]Setting, len(settings))
+	for _, s := range settings {
+		fs.Var(s.Value, s.Name, s.Usage)
+		byName[s.Name] = s
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return false, err
+	}
+
+	// Flags are parsed first, to find the config file, so they're applied
+	// again once the lower precedence layers have been.
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	if *configPath != "" {
+		if err := loadFile(*configPath, byName); err != nil {
+			return false, err
+		}
+	}
+
+	for _, s := range settings {
+		env := envName(envPrefix, s.Name)
+		if v, ok := os.LookupEnv(env); ok {
+			if err := s.Value.Set(v); err != nil {
+				return false, fmt.Errorf("invalid value for %s: %w", env, err)
+			}
+		}
+	}
+
+	for name, v := range flags {
+		if s, ok := byName[name]; ok {
+			if err := s.Value.Set(v); err != nil {
+				return false, fmt.Errorf("invalid value for -%s: %w", name, err)
+			}
+		}
+	}
+
+	return *printConfig, nil
+}
+
+// loadFile applies the settings in the YAML file at path.
+func loadFile(path string, settings map[string]Setting) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	for name, v := range values {
+		s, ok := settings[name]
+		if !ok {
+			return fmt.Errorf("invalid config file %s: unknown setting %q", path, name)
+		}
+
+		if err := s.Value.Set(fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("invalid config file %s: invalid value for %s: %w", path, name, err)
+		}
+	}
+
+	return nil
+}
+
+// Write writes settings as YAML, in the same format accepted by --config, with secrets redacted.
+func Write(w io.Writer, settings []Setting) error {
+	for _, s := range settings {
+		v := s.Value.String()
+		if s.Secret && v != "" {
+			v = redacted
+		}
+
+		if _, err := fmt.Fprintf(w, "%s: %s\n", s.Name, strconv.Quote(v)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// envName returns the environment variable for a setting, e.g. RACING_DB_PATH for db-path.
+func envName(prefix, name string) string {
+	b := []byte(prefix + "_" + name)
+	for i, c := range b {
+		switch {
+		case c == '-':
+			b[i] = '_'
+		case 'a' <= c && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// String returns a flag.Value setting *p.
+func String(p *string) flag.Value { return stringValue{p} }
+
+// Bool returns a flag.Value setting *p, which can be given as a bare flag.
+func Bool(p *bool) flag.Value { return boolValue{p} }
+
+// Int returns a flag.Value setting *p.
+func Int(p *int) flag.Value { return intValue{p} }
+
+// Duration returns a flag.Value setting *p, parsed with time.ParseDuration.
+func Duration(p *time.Duration) flag.Value { return durationValue{p} }
+
+// Each value's String allows for a nil target, as the flag package calls it on
+// zero values to find which defaults to print. It reads as the type's zero
+// value, so zero defaults aren't printed, as with the flag package's own values.
+type stringValue struct{ p *string }
+
+func (v stringValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+func (v stringValue) Set(s string) error { *v.p = s; return nil }
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) String() string {
+	if v.p == nil {
+		return "false"
+	}
+	return strconv.FormatBool(*v.p)
+}
+func (v boolValue) IsBoolFlag() bool { return true }
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v.p = b
+	return nil
+}
+
+type intValue struct{ p *int }
+
+func (v intValue) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.Itoa(*v.p)
+}
+func (v intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v.p = i
+	return nil
+}
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) String() string {
+	if v.p == nil {
+		return "0s"
+	}
+	return v.p.String()
+}
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v.p = d
+	return nil
+}
@@ -0,0 +1,119 @@
+package settings
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Endpoint string
+	Enabled  bool
+	Size     int
+	TTL      time.Duration
+	Secret   string
+}
+
+func bind(c *testConfig) []Setting {
+	return []Setting{
+		{Name: "endpoint", Value: String(&c.Endpoint)},
+		{Name: "enabled", Value: Bool(&c.Enabled)},
+		{Name: "size", Value: Int(&c.Size)},
+		{Name: "ttl", Value: Duration(&c.TTL)},
+		{Name: "secret", Secret: true, Value: String(&c.Secret)},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.yaml")
+	if err := ioutil.WriteFile(path, []byte("size: 10\nttl: 1m\nendpoint: file:1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TEST_SIZE", "20")
+	os.Setenv("TEST_ENDPOINT", "env:1")
+	defer os.Unsetenv("TEST_SIZE")
+	defer os.Unsetenv("TEST_ENDPOINT")
+
+	cfg := testConfig{Endpoint: "default:1", Secret: "default"}
+	printConfig, err := Load([]string{"test", "--endpoint", "flag:1", "--config", path, "--enabled", "--print-config"}, "TEST", bind(&cfg))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !printConfig {
+		t.Errorf("Load() printConfig = false, want true")
+	}
+
+	want := testConfig{Endpoint: "flag:1", Enabled: true, Size: 20, TTL: time.Minute, Secret: "default"}
+	if cfg != want {
+		t.Errorf("Load() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoad_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     string
+		wantErr string
+	}{
+		{name: "flag", args: []string{"--ttl", "soon"}, wantErr: `invalid value "soon" for flag -ttl`},
+		{name: "environment", env: "lots", wantErr: "invalid value for TEST_SIZE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				os.Setenv("TEST_SIZE", tt.env)
+				defer os.Unsetenv("TEST_SIZE")
+			}
+
+			var cfg testConfig
+			_, err := Load(append([]string{"test"}, tt.args...), "TEST", bind(&cfg))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cfg := testConfig{Endpoint: "localhost:1", Size: 3, TTL: time.Second, Secret: "hunter2"}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, bind(&cfg)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := "endpoint: \"localhost:1\"\nenabled: \"false\"\nsize: \"3\"\nttl: \"1s\"\nsecret: \"[REDACTED]\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Write() = %q, want %q", got, want)
+	}
+}
+
+func TestLoad_help(t *testing.T) {
+	var buf bytes.Buffer
+	usageOutput = &buf
+	defer func() { usageOutput = os.Stderr }()
+
+	cfg := testConfig{Endpoint: "default:1", Enabled: true, Size: 10, TTL: time.Minute}
+	_, err := Load([]string{"test", "-h"}, "TEST", bind(&cfg))
+	if err != flag.ErrHelp {
+		t.Fatalf("Load() error = %v, want flag.ErrHelp", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "panic") {
+		t.Errorf("usage = %q, want no panics", out)
+	}
+	for _, want := range []string{"(default default:1)", "(default true)", "(default 10)", "(default 1m0s)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("usage = %q, want containing %s", out, want)
+		}
+	}
+}
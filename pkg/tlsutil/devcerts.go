@@ -0,0 +1,131 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// Files written by GenerateDevCerts.
+const (
+	DevCAFile         = "ca.pem"
+	DevServerCertFile = "server.pem"
+	DevServerKeyFile  = "server-key.pem"
+	DevClientCertFile = "client.pem"
+	DevClientKeyFile  = "client-key.pem"
+)
+
+// devCertValidity is how long generated development certificates are valid for.
+const devCertValidity = 365 * 24 * time.Hour
+
+// GenerateDevCerts writes a throwaway CA, a server certificate valid for hosts
+// (DNS names or IP addresses) and a client certificate, both signed by the CA,
+// into dir. They're for local development and tests only.
+func GenerateDevCerts(dir string, hosts []string) error {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	caTemplate, err := certTemplate("entain dev CA")
+	if err != nil {
+		return err
+	}
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		return err
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(filepath.Join(dir, DevCAFile), "CERTIFICATE", caDER); err != nil {
+		return err
+	}
+
+	serverTemplate, err := certTemplate("entain dev server")
+	if err != nil {
+		return err
+	}
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+
+	if err := issue(dir, DevServerCertFile, DevServerKeyFile, serverTemplate, caCert, caKey); err != nil {
+		return err
+	}
+
+	clientTemplate, err := certTemplate("entain dev client")
+	if err != nil {
+		return err
+	}
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	return issue(dir, DevClientCertFile, DevClientKeyFile, clientTemplate, caCert, caKey)
+}
+
+// issue creates a key and a certificate from template signed by the CA, writing both into dir.
+func issue(dir, certFile, keyFile string, template, ca *x509.Certificate, caKey crypto.Signer) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(filepath.Join(dir, keyFile), "EC PRIVATE KEY", keyDER); err != nil {
+		return err
+	}
+
+	return writePEM(filepath.Join(dir, certFile), "CERTIFICATE", der)
+}
+
+func certTemplate(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"entain dev"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(devCertValidity),
+	}, nil
+}
+
+func writePEM(path, blockType string, der []byte) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+}
@@ -0,0 +1,31 @@
+package tlsutil
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// GRPCServerOptions returns the server options serving gRPC over TLS with the
+// key pair in certFile and keyFile (and mutual TLS, with client certificates
+// signed by clientCAFile, when it's set), or none when certFile is empty.
+func GRPCServerOptions(certFile, keyFile, clientCAFile string) ([]grpc.ServerOption, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+
+	keyPair, err := LoadKeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientCAs *CAPool
+	if clientCAFile != "" {
+		if clientCAs, err = LoadCAPool(clientCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(ServerConfig(keyPair, clientCAs))),
+	}, nil
+}
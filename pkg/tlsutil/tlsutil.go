@@ -0,0 +1,276 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadInterval limits how often certificate files are checked for changes.
+var reloadInterval = time.Second
+
+// KeyPair is a certificate and private key loaded from disk, which is reloaded
+// whenever either file changes, so certificates can be rotated without a restart.
+type KeyPair struct {
+	watcher *watcher
+	cert    *tls.Certificate
+}
+
+// LoadKeyPair loads the PEM encoded certificate and key from the given files.
+func LoadKeyPair(certFile, keyFile string) (*KeyPair, error) {
+	k := &KeyPair{}
+
+	k.watcher = &watcher{
+		files: []string{certFile, keyFile},
+		load: func() error {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return err
+			}
+
+			k.cert = &cert
+
+			return nil
+		},
+	}
+
+	if err := k.watcher.init(); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// GetCertificate returns the current certificate, for use in tls.Config.GetCertificate.
+func (k *KeyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return k.current(), nil
+}
+
+// GetClientCertificate returns the current certificate, for use in tls.Config.GetClientCertificate.
+func (k *KeyPair) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return k.current(), nil
+}
+
+func (k *KeyPair) current() *tls.Certificate {
+	k.watcher.refresh()
+
+	k.watcher.mu.RLock()
+	defer k.watcher.mu.RUnlock()
+
+	return k.cert
+}
+
+// CAPool is a bundle of PEM encoded CA certificates loaded from disk, which is
+// reloaded whenever the file changes.
+type CAPool struct {
+	watcher *watcher
+	pool    *x509.CertPool
+}
+
+// LoadCAPool loads the CA certificates from file.
+func LoadCAPool(file string) (*CAPool, error) {
+	c := &CAPool{}
+
+	c.watcher = &watcher{
+		files: []string{file},
+		load: func() error {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(b) {
+				return fmt.Errorf("no certificates found in %s", file)
+			}
+
+			c.pool = pool
+
+			return nil
+		},
+	}
+
+	if err := c.watcher.init(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Pool returns the current CA certificates.
+func (c *CAPool) Pool() *x509.CertPool {
+	c.watcher.refresh()
+
+	c.watcher.mu.RLock()
+	defer c.watcher.mu.RUnlock()
+
+	return c.pool
+}
+
+// ServerConfig returns a TLS config presenting keyPair. When clientCAs is set,
+// clients must present a certificate signed by one of them (mutual TLS).
+//
+// Certificates and CAs are looked up per handshake, so reloads are picked up,
+// without replacing the config, so protocols such as h2 that servers add to
+// their copy of it are still negotiated.
+func ServerConfig(keyPair *KeyPair, clientCAs *CAPool) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: keyPair.GetCertificate,
+	}
+
+	if clientCAs != nil {
+		// Standard verification would pin the CAs for the config's lifetime,
+		// so verify against the current pool ourselves instead.
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("client presented no certificate")
+			}
+
+			return verifyChain(state.PeerCertificates, x509.VerifyOptions{
+				Roots:     clientCAs.Pool(),
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			})
+		}
+	}
+
+	return cfg
+}
+
+// ClientConfig returns a TLS config verifying servers against rootCAs (or the
+// system roots when nil), presenting keyPair when set (mutual TLS).
+func ClientConfig(rootCAs *CAPool, keyPair *KeyPair, serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if keyPair != nil {
+		cfg.GetClientCertificate = keyPair.GetClientCertificate
+	}
+
+	if rootCAs != nil {
+		// Standard verification would pin the roots for the config's lifetime,
+		// so verify against the current pool ourselves instead.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+
+			return verifyChain(state.PeerCertificates, x509.VerifyOptions{
+				DNSName: state.ServerName,
+				Roots:   rootCAs.Pool(),
+			})
+		}
+	}
+
+	return cfg
+}
+
+// verifyChain verifies the leaf of certs, the peer's chain as presented, with
+// the rest of the chain as intermediates.
+func verifyChain(certs []*x509.Certificate, opts x509.VerifyOptions) error {
+	opts.Intermediates = x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(opts)
+
+	return err
+}
+
+// watcher reloads a value from a set of files when any of their modification
+// times change, checking at most once every reloadInterval.
+type watcher struct {
+	files []string
+	load  func() error
+
+	mu        sync.RWMutex
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+func (w *watcher) init() error {
+	modTimes, err := w.stat()
+	if err != nil {
+		return err
+	}
+
+	if err := w.load(); err != nil {
+		return err
+	}
+
+	w.modTimes = modTimes
+	w.checkedAt = time.Now()
+
+	return nil
+}
+
+// refresh reloads the files if they've changed. Failed reloads, such as when
+// only one of a certificate and key has been replaced so far, keep the
+// previously loaded value and are retried on the next check.
+func (w *watcher) refresh() {
+	w.mu.RLock()
+	due := time.Since(w.checkedAt) >= reloadInterval
+	w.mu.RUnlock()
+
+	if !due {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if time.Since(w.checkedAt) < reloadInterval {
+		return
+	}
+	w.checkedAt = time.Now()
+
+	modTimes, err := w.stat()
+	if err != nil || equalTimes(modTimes, w.modTimes) {
+		return
+	}
+
+	if err := w.load(); err != nil {
+		return
+	}
+
+	w.modTimes = modTimes
+}
+
+func (w *watcher) stat() ([]time.Time, error) {
+	modTimes := make([]time.Time, 0, len(w.files))
+
+	for _, file := range w.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	return modTimes, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,181 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateDevCerts(dir, []string{"localhost", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	serverCert := mustLoadKeyPair(t, dir, DevServerCertFile, DevServerKeyFile)
+	clientCert := mustLoadKeyPair(t, dir, DevClientCertFile, DevClientKeyFile)
+	ca := mustLoadCAPool(t, dir)
+
+	tests := map[string]struct {
+		server  *tls.Config
+		client  *tls.Config
+		wantErr bool
+	}{
+		"server tls": {
+			server: ServerConfig(serverCert, nil),
+			client: ClientConfig(ca, nil, "localhost"),
+		},
+		"mutual tls": {
+			server: ServerConfig(serverCert, ca),
+			client: ClientConfig(ca, clientCert, "localhost"),
+		},
+		"missing client certificate": {
+			server:  ServerConfig(serverCert, ca),
+			client:  ClientConfig(ca, nil, "localhost"),
+			wantErr: true,
+		},
+		"wrong server name": {
+			server:  ServerConfig(serverCert, nil),
+			client:  ClientConfig(ca, nil, "racing.example.com"),
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := handshake(tt.server, tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handshake() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	defer func(interval time.Duration) { reloadInterval = interval }(reloadInterval)
+	reloadInterval = 0
+
+	dir := t.TempDir()
+	if err := GenerateDevCerts(dir, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+
+	serverCert := mustLoadKeyPair(t, dir, DevServerCertFile, DevServerKeyFile)
+	clientCert := mustLoadKeyPair(t, dir, DevClientCertFile, DevClientKeyFile)
+	ca := mustLoadCAPool(t, dir)
+
+	server := ServerConfig(serverCert, ca)
+	client := ClientConfig(ca, clientCert, "localhost")
+
+	before, err := handshake(server, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate everything, including the CA, without touching the configs.
+	if err := GenerateDevCerts(dir, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := handshake(server, client)
+	if err != nil {
+		t.Fatalf("handshake() after rotation error = %v", err)
+	}
+
+	if bytes.Equal(before.PeerCertificates[0].Raw, after.PeerCertificates[0].Raw) {
+		t.Error("server still presenting the certificate from before rotation")
+	}
+}
+
+func TestServerConfig_negotiatesProtocols(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateDevCerts(dir, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ca := mustLoadCAPool(t, dir)
+
+	for name, clientCAs := range map[string]*CAPool{"server tls": nil, "mutual tls": ca} {
+		t.Run(name, func(t *testing.T) {
+			// Servers such as net/http and gRPC add h2 to their own copy of the config.
+			server := ServerConfig(mustLoadKeyPair(t, dir, DevServerCertFile, DevServerKeyFile), clientCAs).Clone()
+			server.NextProtos = []string{"h2", "http/1.1"}
+
+			client := ClientConfig(ca, mustLoadKeyPair(t, dir, DevClientCertFile, DevClientKeyFile), "localhost")
+			client.NextProtos = []string{"h2"}
+
+			state, err := handshake(server, client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state.NegotiatedProtocol != "h2" {
+				t.Errorf("NegotiatedProtocol = %q, want %q", state.NegotiatedProtocol, "h2")
+			}
+		})
+	}
+}
+
+func TestLoadKeyPair_missing(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := LoadKeyPair(filepath.Join(dir, DevServerCertFile), filepath.Join(dir, DevServerKeyFile)); err == nil {
+		t.Error("LoadKeyPair() expected an error for missing files")
+	}
+}
+
+// handshake runs a TLS handshake between the server and client configs,
+// returning the connection state seen by the client.
+func handshake(server, client *tls.Config) (tls.ConnectionState, error) {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer lis.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+
+		serverErr <- conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), client)
+	if err != nil {
+		<-serverErr
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+
+	// Under TLS 1.3 the client finishes before the server has verified its
+	// certificate, so rejections only show up on the server side.
+	return conn.ConnectionState(), <-serverErr
+}
+
+func mustLoadKeyPair(t *testing.T, dir, certFile, keyFile string) *KeyPair {
+	t.Helper()
+
+	keyPair, err := LoadKeyPair(filepath.Join(dir, certFile), filepath.Join(dir, keyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keyPair
+}
+
+func mustLoadCAPool(t *testing.T, dir string) *CAPool {
+	t.Helper()
+
+	ca, err := LoadCAPool(filepath.Join(dir, DevCAFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ca
+}
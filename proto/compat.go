@@ -0,0 +1,15 @@
+package proto
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/proto/sports"
+)
+
+// Files are the protos in this module. Their contracts are checked for breaking
+// changes against the baseline in compat/baseline.binpb.
+var Files = []protoreflect.FileDescriptor{
+	racing.File_racing_racing_proto,
+	sports.File_sports_sports_proto,
+}
@@ -0,0 +1,30 @@
+package proto
+
+import (
+	"testing"
+
+	"git.neds.sh/matty/entain/proto/internal/compat"
+)
+
+// TestCompatible fails when the protos break the contract in the baseline,
+// other than by the intentional changes in the allowlist.
+func TestCompatible(t *testing.T) {
+	baseline, err := compat.ReadFileSet("compat/baseline.binpb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allow, err := compat.ReadAllowlist("compat/allow.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	breaking, unused := allow.Filter(compat.Check(baseline, compat.FileSet(Files...)))
+
+	for _, change := range breaking {
+		t.Errorf("breaking change %s; add it to compat/allow.yaml if it's intentional", change)
+	}
+	for _, entry := range unused {
+		t.Errorf("compat/allow.yaml entry %s %s matches no change; remove it", entry.Kind, entry.Element)
+	}
+}
@@ -0,0 +1,24 @@
+// Package proto holds the protos shared by the racing and sports services and
+// the api gateway, and the code generated from them. The gateway's HTTP
+// bindings are kept out of the protos, in racing/racing_gateway.yaml. Sports
+// has no HTTP routes of its own; its events are only listed by /v1/upcoming.
+package proto
+
+import _ "embed" // Embeds the generated OpenAPI spec and the gateway config.
+
+//go:generate protoc -I . --go_out . --go_opt paths=source_relative --go-grpc_out . --go-grpc_opt paths=source_relative,require_unimplemented_servers=false --grpc-gateway_out gateway --grpc-gateway_opt paths=source_relative,standalone=true,grpc_api_configuration=racing/racing_gateway.yaml --openapiv2_out . --openapiv2_opt logtostderr=true,disable_default_errors=true,grpc_api_configuration=racing/racing_gateway.yaml,openapi_configuration=racing/racing_openapi.yaml racing/racing.proto --experimental_allow_proto3_optional
+//go:generate protoc -I . --go_out . --go_opt paths=source_relative --go-grpc_out . --go-grpc_opt paths=source_relative,require_unimplemented_servers=false sports/sports.proto --experimental_allow_proto3_optional
+//go:generate protoc -I . --go_out . --go_opt module=git.neds.sh/matty/entain/proto entain/validate/validate.proto
+//go:generate go run ./internal/cmd/inputsum -o generated.sum racing/racing.proto racing/racing_gateway.yaml racing/racing_openapi.yaml entain/validate/validate.proto sports/sports.proto
+
+// OpenAPISpec is the OpenAPI v2 (Swagger) spec of the gateway's HTTP routes,
+// generated by protoc-gen-openapiv2.
+//
+//go:embed racing/racing.swagger.json
+var OpenAPISpec []byte
+
+// GatewayConfig is the gRPC API configuration binding the racing RPCs to the
+// gateway's HTTP routes.
+//
+//go:embed racing/racing_gateway.yaml
+var GatewayConfig []byte
@@ -0,0 +1,156 @@
+package proto
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"git.neds.sh/matty/entain/proto/internal/inputsum"
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// TestGenerated fails when a proto or gateway config has changed since the
+// code was last generated. It only compares the inputs, so it can't catch
+// generated files edited by hand: CI guards against those, by regenerating
+// and failing on any diff in git.
+func TestGenerated(t *testing.T) {
+	if err := inputsum.Verify("generated.sum"); err != nil {
+		t.Error(err)
+	}
+}
+
+// openAPIDoc is the subset of the spec checked for drift.
+type openAPIDoc struct {
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Enum       []string                   `json:"enum"`
+	} `json:"definitions"`
+}
+
+// TestOpenAPISpec fails when the embedded spec doesn't describe the routes in
+// the gateway config, or the messages and enums of the protos.
+func TestOpenAPISpec(t *testing.T) {
+	var doc openAPIDoc
+	if err := json.Unmarshal(OpenAPISpec, &doc); err != nil {
+		t.Fatalf("failed parsing spec: %s", err)
+	}
+
+	b, err := ioutil.ReadFile("racing/racing_gateway.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseHTTPRules(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wantRoutes, gotRoutes []string
+
+	for _, rule := range rules {
+		for _, binding := range rule.Bindings() {
+			method, path := binding.Pattern()
+			wantRoutes = append(wantRoutes, method+" "+path)
+		}
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			gotRoutes = append(gotRoutes, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	assertSameSet(t, "routes", gotRoutes, wantRoutes)
+
+	file := racing.File_racing_racing_proto
+
+	messages := file.Messages()
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+
+		def, ok := doc.Definitions[definitionName(message.FullName())]
+		if !ok {
+			// Requests bound entirely to the path, such as GetRaceRequest
+			// and PutRaceRequest, are described by their operation's
+			// parameters instead.
+			if !boundToPath(message, doc) {
+				t.Errorf("spec is missing message %s", message.FullName())
+			}
+			continue
+		}
+
+		var want, got []string
+		fields := message.Fields()
+		for j := 0; j < fields.Len(); j++ {
+			want = append(want, fields.Get(j).JSONName())
+		}
+		for name := range def.Properties {
+			got = append(got, name)
+		}
+
+		assertSameSet(t, string(message.FullName())+" fields", got, want)
+	}
+
+	enums := file.Enums()
+	for i := 0; i < enums.Len(); i++ {
+		enum := enums.Get(i)
+
+		def, ok := doc.Definitions[definitionName(enum.FullName())]
+		if !ok {
+			t.Errorf("spec is missing enum %s", enum.FullName())
+			continue
+		}
+
+		var want []string
+		values := enum.Values()
+		for j := 0; j < values.Len(); j++ {
+			want = append(want, string(values.Get(j).Name()))
+		}
+
+		assertSameSet(t, string(enum.FullName())+" values", def.Enum, want)
+	}
+}
+
+// boundToPath reports whether every field of message is a parameter of a path
+// in the spec, or holds one, as race does in /v1/races/{race.id}.
+func boundToPath(message protoreflect.MessageDescriptor, doc openAPIDoc) bool {
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		name := string(fields.Get(i).Name())
+
+		bound := false
+		for path := range doc.Paths {
+			if strings.Contains(path, "{"+name+"}") || strings.Contains(path, "{"+name+".") {
+				bound = true
+			}
+		}
+
+		if !bound {
+			return false
+		}
+	}
+
+	return fields.Len() > 0
+}
+
+// definitionName returns the spec definition name protoc-gen-openapiv2 gives a
+// top level type, e.g. racingListRacesRequest for racing.ListRacesRequest.
+func definitionName(name protoreflect.FullName) string {
+	return strings.ReplaceAll(string(name.Parent()), ".", "") + string(name.Name())
+}
+
+func assertSameSet(t *testing.T, what string, got, want []string) {
+	t.Helper()
+
+	sort.Strings(got)
+	sort.Strings(want)
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("spec %s = %v, protos have %v; run go generate ./...", what, got, want)
+	}
+}
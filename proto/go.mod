@@ -0,0 +1,12 @@
+module git.neds.sh/matty/entain/proto
+
+go 1.16
+
+require (
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.3.0
+	google.golang.org/genproto v0.0.0-20210226172003-ab064af71705
+	google.golang.org/grpc v1.41.0
+	google.golang.org/grpc/cmd/protoc-gen-go-grpc v1.1.0
+	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v2 v2.3.0
+)
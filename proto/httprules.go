@@ -0,0 +1,57 @@
+package proto
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HTTPRule is a binding in a gRPC API configuration's http section, such as
+// GatewayConfig's.
+type HTTPRule struct {
+	Selector           string     `yaml:"selector"`
+	Get                string     `yaml:"get"`
+	Post               string     `yaml:"post"`
+	Put                string     `yaml:"put"`
+	Patch              string     `yaml:"patch"`
+	Delete             string     `yaml:"delete"`
+	AdditionalBindings []HTTPRule `yaml:"additional_bindings"`
+}
+
+// ParseHTTPRules reads the HTTP rules from a gRPC API configuration.
+func ParseHTTPRules(config []byte) ([]HTTPRule, error) {
+	var service struct {
+		HTTP struct {
+			Rules []HTTPRule `yaml:"rules"`
+		} `yaml:"http"`
+	}
+	if err := yaml.Unmarshal(config, &service); err != nil {
+		return nil, fmt.Errorf("parsing gateway config: %w", err)
+	}
+
+	return service.HTTP.Rules, nil
+}
+
+// Bindings returns the rule followed by its additional bindings.
+func (r HTTPRule) Bindings() []HTTPRule {
+	return append([]HTTPRule{r}, r.AdditionalBindings...)
+}
+
+// Pattern returns the HTTP method and path pattern the rule binds, e.g. GET
+// and /v1/races/{id}, ignoring its additional bindings.
+func (r HTTPRule) Pattern() (method, path string) {
+	for _, binding := range []struct{ method, path string }{
+		{http.MethodGet, r.Get},
+		{http.MethodPost, r.Post},
+		{http.MethodPut, r.Put},
+		{http.MethodPatch, r.Patch},
+		{http.MethodDelete, r.Delete},
+	} {
+		if binding.path != "" {
+			return binding.method, binding.path
+		}
+	}
+
+	return "", ""
+}
@@ -0,0 +1,55 @@
+// Command compat reports breaking changes to the protos since the baseline, or
+// with -update, makes the current protos the new baseline.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"git.neds.sh/matty/entain/proto"
+	"git.neds.sh/matty/entain/proto/internal/compat"
+)
+
+var (
+	baseline  = flag.String("baseline", "compat/baseline.binpb", "Descriptor set of the released protos")
+	allowlist = flag.String("allow", "compat/allow.yaml", "Intentional breaking changes since the baseline")
+	update    = flag.Bool("update", false, "Replace the baseline with the current protos")
+)
+
+func main() {
+	flag.Parse()
+
+	current := compat.FileSet(proto.Files...)
+
+	if *update {
+		if err := compat.WriteFileSet(*baseline, current); err != nil {
+			log.Fatalf("failed writing baseline: %s", err)
+		}
+		return
+	}
+
+	base, err := compat.ReadFileSet(*baseline)
+	if err != nil {
+		log.Fatalf("failed reading baseline: %s", err)
+	}
+
+	allow, err := compat.ReadAllowlist(*allowlist)
+	if err != nil {
+		log.Fatalf("failed reading allowlist: %s", err)
+	}
+
+	breaking, unused := allow.Filter(compat.Check(base, current))
+
+	for _, change := range breaking {
+		fmt.Println(change)
+	}
+	for _, entry := range unused {
+		fmt.Printf("unused allowlist entry %s %s\n", entry.Kind, entry.Element)
+	}
+
+	if len(breaking) > 0 || len(unused) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,25 @@
+// Command inputsum writes the checksums of the files code was generated from.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+
+	"git.neds.sh/matty/entain/proto/internal/inputsum"
+)
+
+var out = flag.String("o", "", "File to write the checksums to")
+
+func main() {
+	flag.Parse()
+
+	sum, err := inputsum.Sum(flag.Args())
+	if err != nil {
+		log.Fatalf("failed summing inputs: %s", err)
+	}
+
+	if err := ioutil.WriteFile(*out, []byte(sum), 0644); err != nil {
+		log.Fatalf("failed writing %s: %s", *out, err)
+	}
+}
@@ -0,0 +1,72 @@
+package compat
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Allowed is an intentional breaking change, accepted until the baseline is
+// next updated.
+type Allowed struct {
+	Kind    Kind   `yaml:"kind"`
+	Element string `yaml:"element"`
+	Reason  string `yaml:"reason"`
+}
+
+// Allowlist holds the intentional breaking changes since the baseline.
+type Allowlist struct {
+	Allow []Allowed `yaml:"allow"`
+}
+
+// ReadAllowlist reads an allowlist from a YAML file. Every entry must give the
+// reason for the break, for the benefit of its reviewers.
+func ReadAllowlist(path string) (Allowlist, error) {
+	var list Allowlist
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return list, err
+	}
+
+	if err := yaml.UnmarshalStrict(b, &list); err != nil {
+		return list, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, allowed := range list.Allow {
+		if allowed.Kind == "" || allowed.Element == "" || allowed.Reason == "" {
+			return list, fmt.Errorf("%s: entry %+v needs a kind, element and reason", path, allowed)
+		}
+	}
+
+	return list, nil
+}
+
+// Filter returns the changes not on the list, and the entries on the list that
+// match no change, which are stale and should be removed.
+func (l Allowlist) Filter(changes []Change) (breaking []Change, unused []Allowed) {
+	matched := make(map[Allowed]bool)
+
+	for _, change := range changes {
+		allowed := false
+		for _, entry := range l.Allow {
+			if entry.Kind == change.Kind && entry.Element == change.Element {
+				matched[entry] = true
+				allowed = true
+			}
+		}
+
+		if !allowed {
+			breaking = append(breaking, change)
+		}
+	}
+
+	for _, entry := range l.Allow {
+		if !matched[entry] {
+			unused = append(unused, entry)
+		}
+	}
+
+	return breaking, unused
+}
@@ -0,0 +1,284 @@
+// Package compat detects changes to proto contracts that would break existing
+// clients, by comparing descriptors against a committed baseline.
+package compat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Kind is the kind of a breaking change.
+type Kind string
+
+// The kinds of breaking change. Renames are breaking because the JSON encoding,
+// and so the gateway's HTTP API, uses field and enum value names.
+const (
+	MessageRemoved    Kind = "MESSAGE_REMOVED"
+	FieldRemoved      Kind = "FIELD_REMOVED"
+	FieldRenamed      Kind = "FIELD_RENAMED"
+	FieldTypeChanged  Kind = "FIELD_TYPE_CHANGED"
+	FieldNumberReused Kind = "FIELD_NUMBER_REUSED"
+	EnumRemoved       Kind = "ENUM_REMOVED"
+	EnumValueRemoved  Kind = "ENUM_VALUE_REMOVED"
+	EnumValueRenamed  Kind = "ENUM_VALUE_RENAMED"
+	ServiceRemoved    Kind = "SERVICE_REMOVED"
+	MethodRemoved     Kind = "RPC_REMOVED"
+	MethodChanged     Kind = "RPC_SIGNATURE_CHANGED"
+)
+
+// Change is a breaking change to an element of the baseline.
+type Change struct {
+	Kind Kind
+	// Element is the full name of the changed element in the baseline, e.g.
+	// racing.Race.name for a field.
+	Element string
+	Detail  string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Kind, c.Element, c.Detail)
+}
+
+// FileSet returns a descriptor set of files, without their dependencies.
+func FileSet(files ...protoreflect.FileDescriptor) *descriptorpb.FileDescriptorSet {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range files {
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(file))
+	}
+
+	return set
+}
+
+// ReadFileSet reads a binary encoded descriptor set.
+func ReadFileSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return set, nil
+}
+
+// WriteFileSet writes set to path in the binary encoding, deterministically so
+// an unchanged baseline is rewritten byte for byte.
+func WriteFileSet(path string, set *descriptorpb.FileDescriptorSet) error {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Check returns the breaking changes from baseline to current, ordered by
+// element. Additions are never breaking, and neither are changes that keep the
+// wire and JSON encodings compatible.
+func Check(baseline, current *descriptorpb.FileDescriptorSet) []Change {
+	before, after := index(baseline), index(current)
+
+	var changes []Change
+	report := func(kind Kind, element, format string, args ...interface{}) {
+		changes = append(changes, Change{Kind: kind, Element: element, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	for name, old := range before.messages {
+		message, ok := after.messages[name]
+		if !ok {
+			report(MessageRemoved, name, "message removed")
+			continue
+		}
+
+		checkFields(name, old, message, report)
+	}
+
+	for name, old := range before.enums {
+		enum, ok := after.enums[name]
+		if !ok {
+			report(EnumRemoved, name, "enum removed")
+			continue
+		}
+
+		values := make(map[int32]string)
+		for _, value := range enum.GetValue() {
+			values[value.GetNumber()] = value.GetName()
+		}
+
+		for _, value := range old.GetValue() {
+			element := name + "." + value.GetName()
+
+			newName, ok := values[value.GetNumber()]
+			switch {
+			case !ok:
+				report(EnumValueRemoved, element, "value %d removed", value.GetNumber())
+			case newName != value.GetName():
+				report(EnumValueRenamed, element, "value %d renamed to %s", value.GetNumber(), newName)
+			}
+		}
+	}
+
+	for name, old := range before.services {
+		service, ok := after.services[name]
+		if !ok {
+			report(ServiceRemoved, name, "service removed")
+			continue
+		}
+
+		methods := make(map[string]*descriptorpb.MethodDescriptorProto)
+		for _, method := range service.GetMethod() {
+			methods[method.GetName()] = method
+		}
+
+		for _, old := range old.GetMethod() {
+			element := name + "." + old.GetName()
+
+			method, ok := methods[old.GetName()]
+			if !ok {
+				report(MethodRemoved, element, "rpc removed")
+				continue
+			}
+
+			if was, is := signature(old), signature(method); was != is {
+				report(MethodChanged, element, "signature changed from %s to %s", was, is)
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Element != changes[j].Element {
+			return changes[i].Element < changes[j].Element
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+
+	return changes
+}
+
+// checkFields reports breaking changes to the fields of a message, matching
+// fields by number as the wire encoding does.
+func checkFields(name string, old, message *descriptorpb.DescriptorProto, report func(Kind, string, string, ...interface{})) {
+	fields := make(map[int32]*descriptorpb.FieldDescriptorProto)
+	for _, field := range message.GetField() {
+		fields[field.GetNumber()] = field
+	}
+
+	for _, oldField := range old.GetField() {
+		element := name + "." + oldField.GetName()
+
+		field, ok := fields[oldField.GetNumber()]
+		if !ok {
+			report(FieldRemoved, element, "field %d removed", oldField.GetNumber())
+			continue
+		}
+
+		renamed := field.GetName() != oldField.GetName()
+		retyped := fieldType(field) != fieldType(oldField)
+
+		switch {
+		case renamed && retyped:
+			report(FieldNumberReused, element, "field %d reused by %s %s", field.GetNumber(), fieldType(field), field.GetName())
+		case renamed:
+			report(FieldRenamed, element, "field %d renamed to %s", field.GetNumber(), field.GetName())
+		case retyped:
+			report(FieldTypeChanged, element, "type changed from %s to %s", fieldType(oldField), fieldType(field))
+		}
+	}
+
+	// Numbers reserved in the baseline belonged to fields removed before it.
+	for _, field := range message.GetField() {
+		for _, reserved := range old.GetReservedRange() {
+			// Reserved range ends are exclusive in descriptors.
+			if field.GetNumber() >= reserved.GetStart() && field.GetNumber() < reserved.GetEnd() {
+				report(FieldNumberReused, name+"."+field.GetName(), "field %d was reserved", field.GetNumber())
+			}
+		}
+	}
+}
+
+// fieldType describes the wire type and cardinality of a field, e.g.
+// "repeated int64" or "optional .racing.Race".
+func fieldType(field *descriptorpb.FieldDescriptorProto) string {
+	typ := field.GetTypeName()
+	if typ == "" {
+		typ = protoreflect.Kind(field.GetType()).String()
+	}
+
+	label := "optional"
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		label = "repeated"
+	}
+
+	return label + " " + typ
+}
+
+// signature describes the request and response of a method, e.g.
+// "(.racing.ListRacesRequest) returns (stream .racing.Race)".
+func signature(method *descriptorpb.MethodDescriptorProto) string {
+	stream := func(streaming bool) string {
+		if streaming {
+			return "stream "
+		}
+		return ""
+	}
+
+	return fmt.Sprintf("(%s%s) returns (%s%s)",
+		stream(method.GetClientStreaming()), method.GetInputType(),
+		stream(method.GetServerStreaming()), method.GetOutputType())
+}
+
+// descriptors indexes the messages, enums and services of a set by full name.
+type descriptors struct {
+	messages map[string]*descriptorpb.DescriptorProto
+	enums    map[string]*descriptorpb.EnumDescriptorProto
+	services map[string]*descriptorpb.ServiceDescriptorProto
+}
+
+func index(set *descriptorpb.FileDescriptorSet) descriptors {
+	d := descriptors{
+		messages: make(map[string]*descriptorpb.DescriptorProto),
+		enums:    make(map[string]*descriptorpb.EnumDescriptorProto),
+		services: make(map[string]*descriptorpb.ServiceDescriptorProto),
+	}
+
+	for _, file := range set.GetFile() {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = file.GetPackage() + "."
+		}
+
+		d.addMessages(prefix, file.GetMessageType())
+		d.addEnums(prefix, file.GetEnumType())
+
+		for _, service := range file.GetService() {
+			d.services[prefix+service.GetName()] = service
+		}
+	}
+
+	return d
+}
+
+func (d descriptors) addMessages(prefix string, messages []*descriptorpb.DescriptorProto) {
+	for _, message := range messages {
+		name := prefix + message.GetName()
+		d.messages[name] = message
+
+		d.addMessages(name+".", message.GetNestedType())
+		d.addEnums(name+".", message.GetEnumType())
+	}
+}
+
+func (d descriptors) addEnums(prefix string, enums []*descriptorpb.EnumDescriptorProto) {
+	for _, enum := range enums {
+		d.enums[prefix+enum.GetName()] = enum
+	}
+}
@@ -0,0 +1,181 @@
+package compat
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestCheck(t *testing.T) {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+	}
+
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		int64T   = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		stringT  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	)
+
+	file := func(fields []*descriptorpb.FieldDescriptorProto, reserved []*descriptorpb.DescriptorProto_ReservedRange, values []string, methods ...*descriptorpb.MethodDescriptorProto) *descriptorpb.FileDescriptorSet {
+		enum := &descriptorpb.EnumDescriptorProto{Name: proto.String("Status")}
+		for i, value := range values {
+			enum.Value = append(enum.Value, &descriptorpb.EnumValueDescriptorProto{Name: proto.String(value), Number: proto.Int32(int32(i))})
+		}
+
+		return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("racing/racing.proto"),
+			Package: proto.String("racing"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name:          proto.String("Race"),
+				Field:         fields,
+				ReservedRange: reserved,
+			}},
+			EnumType: []*descriptorpb.EnumDescriptorProto{enum},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name:   proto.String("Racing"),
+				Method: methods,
+			}},
+		}}}
+	}
+
+	method := func(name, input string, serverStreaming bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(input),
+			OutputType:      proto.String(".racing.Race"),
+			ServerStreaming: proto.Bool(serverStreaming),
+		}
+	}
+
+	values := []string{"OPEN", "CLOSED"}
+	fields := []*descriptorpb.FieldDescriptorProto{
+		field("id", 1, int64T, optional),
+		field("name", 2, stringT, optional),
+	}
+	reserved := []*descriptorpb.DescriptorProto_ReservedRange{{Start: proto.Int32(5), End: proto.Int32(7)}}
+	methods := []*descriptorpb.MethodDescriptorProto{
+		method("GetRace", ".racing.Race", false),
+		method("ListRaces", ".racing.Race", true),
+	}
+
+	baseline := file(fields, reserved, values, methods...)
+
+	tests := []struct {
+		name    string
+		current *descriptorpb.FileDescriptorSet
+		want    []Change
+	}{
+		{
+			name:    "unchanged",
+			current: baseline,
+		},
+		{
+			name: "additions",
+			current: file(
+				append(fields, field("number", 3, int64T, optional)),
+				reserved,
+				append(values, "ABANDONED"),
+				append(methods, method("WatchRaces", ".racing.Race", true))...,
+			),
+		},
+		{
+			name: "field removed",
+			current: file(
+				fields[:1],
+				reserved, values, methods...,
+			),
+			want: []Change{{FieldRemoved, "racing.Race.name", "field 2 removed"}},
+		},
+		{
+			name: "field renamed",
+			current: file(
+				[]*descriptorpb.FieldDescriptorProto{fields[0], field("title", 2, stringT, optional)},
+				reserved, values, methods...,
+			),
+			want: []Change{{FieldRenamed, "racing.Race.name", "field 2 renamed to title"}},
+		},
+		{
+			name: "field type changed",
+			current: file(
+				[]*descriptorpb.FieldDescriptorProto{fields[0], field("name", 2, stringT, repeated)},
+				reserved, values, methods...,
+			),
+			want: []Change{{FieldTypeChanged, "racing.Race.name", "type changed from optional string to repeated string"}},
+		},
+		{
+			name: "field number reused",
+			current: file(
+				[]*descriptorpb.FieldDescriptorProto{fields[0], field("number", 2, int64T, optional), field("venue", 6, stringT, optional)},
+				reserved, values, methods...,
+			),
+			want: []Change{
+				{FieldNumberReused, "racing.Race.name", "field 2 reused by optional int64 number"},
+				{FieldNumberReused, "racing.Race.venue", "field 6 was reserved"},
+			},
+		},
+		{
+			name:    "enum value removed and renamed",
+			current: file(fields, reserved, []string{"SCHEDULED"}, methods...),
+			want: []Change{
+				{EnumValueRemoved, "racing.Status.CLOSED", "value 1 removed"},
+				{EnumValueRenamed, "racing.Status.OPEN", "value 0 renamed to SCHEDULED"},
+			},
+		},
+		{
+			name:    "rpc removed and changed",
+			current: file(fields, reserved, values, method("GetRace", ".racing.Race", true)),
+			want: []Change{
+				{MethodChanged, "racing.Racing.GetRace", "signature changed from (.racing.Race) returns (.racing.Race) to (.racing.Race) returns (stream .racing.Race)"},
+				{MethodRemoved, "racing.Racing.ListRaces", "rpc removed"},
+			},
+		},
+		{
+			name:    "everything removed",
+			current: &descriptorpb.FileDescriptorSet{},
+			want: []Change{
+				{MessageRemoved, "racing.Race", "message removed"},
+				{ServiceRemoved, "racing.Racing", "service removed"},
+				{EnumRemoved, "racing.Status", "enum removed"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Check(baseline, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowlist_Filter(t *testing.T) {
+	changes := []Change{
+		{Kind: FieldRemoved, Element: "racing.Race.name"},
+		{Kind: FieldRenamed, Element: "racing.Race.number"},
+	}
+
+	list := Allowlist{Allow: []Allowed{
+		{Kind: FieldRemoved, Element: "racing.Race.name", Reason: "unused"},
+		{Kind: FieldRemoved, Element: "racing.Race.number", Reason: "unused"},
+	}}
+
+	breaking, unused := list.Filter(changes)
+
+	if !reflect.DeepEqual(breaking, changes[1:]) {
+		t.Errorf("Filter() breaking = %v, want %v", breaking, changes[1:])
+	}
+	if !reflect.DeepEqual(unused, list.Allow[1:]) {
+		t.Errorf("Filter() unused = %v, want %v", unused, list.Allow[1:])
+	}
+}
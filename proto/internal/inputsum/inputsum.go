@@ -0,0 +1,59 @@
+// Package inputsum records checksums of the files code is generated from, so
+// tests can detect generated code that's stale because its inputs changed.
+// The generated code itself isn't checked, so edits made to it by hand go
+// unnoticed until it's next regenerated.
+package inputsum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Sum returns the SHA-256 checksum of each file, in sha256sum's format.
+func Sum(files []string) (string, error) {
+	var b strings.Builder
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(sum[:]), file)
+	}
+
+	return b.String(), nil
+}
+
+// Verify checks the files listed in sumFile still have the recorded checksums.
+func Verify(sumFile string) error {
+	recorded, err := ioutil.ReadFile(sumFile)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(string(recorded)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s: malformed line %q", sumFile, line)
+		}
+
+		files = append(files, fields[1])
+	}
+
+	current, err := Sum(files)
+	if err != nil {
+		return err
+	}
+
+	if current != string(recorded) {
+		return fmt.Errorf("generated code is stale, run go generate ./...\nrecorded:\n%scurrent:\n%s", recorded, current)
+	}
+
+	return nil
+}
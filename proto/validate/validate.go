@@ -0,0 +1,162 @@
+// Package validate checks messages against the rules declared on their fields
+// with the (entain.validate.rules) option, defined in
+// entain/validate/validate.proto.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Violation is a field breaking one of its rules.
+type Violation struct {
+	// Field is the path of the field, e.g. filter.meeting_ids[2].
+	Field string
+	// Description says what's wrong, e.g. "must be greater than 0".
+	Description string
+}
+
+// Error lists every violation found in a message.
+type Error []Violation
+
+func (e Error) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Field + ": " + v.Description
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Message checks m and the messages nested in it against their fields' rules,
+// returning an Error listing every violation, or nil if there are none.
+func Message(m proto.Message) error {
+	var errs Error
+	checkMessage(m.ProtoReflect(), "", &errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func checkMessage(m protoreflect.Message, prefix string, errs *Error) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+
+		// Fields with explicit presence are optional, so only checked when set.
+		if fd.HasPresence() && !m.Has(fd) {
+			continue
+		}
+
+		rules, _ := proto.GetExtension(fd.Options(), E_Rules).(*FieldRules)
+
+		switch {
+		case fd.IsMap():
+			// No map fields have rules yet.
+		case fd.IsList():
+			checkList(fd, m.Get(fd).List(), rules, path, errs)
+		default:
+			checkValue(fd, m.Get(fd), rules, path, errs)
+		}
+	}
+}
+
+// checkList checks a repeated field and each of its elements. Elements of
+// lists that are too long aren't checked, so the error stays short.
+func checkList(fd protoreflect.FieldDescriptor, list protoreflect.List, rules *FieldRules, path string, errs *Error) {
+	repeated := rules.GetRepeated()
+	if repeated != nil && repeated.MaxItems != nil && uint64(list.Len()) > repeated.GetMaxItems() {
+		errs.add(path, "must hold at most %d items", repeated.GetMaxItems())
+		return
+	}
+
+	// Messages aren't comparable, so can't be required to be unique.
+	unique := repeated.GetUnique() && fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind
+	seen := make(map[interface{}]bool)
+
+	for j := 0; j < list.Len(); j++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, j)
+		v := list.Get(j)
+
+		checkValue(fd, v, rules, itemPath, errs)
+
+		if unique {
+			if seen[v.Interface()] {
+				errs.add(itemPath, "must not repeat an earlier item")
+			}
+			seen[v.Interface()] = true
+		}
+	}
+}
+
+// checkValue checks a singular field, or an element of a repeated one.
+func checkValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, rules *FieldRules, path string, errs *Error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		checkMessage(v.Message(), path+".", errs)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		checkInt(v.Int(), rules.GetInt(), path, errs)
+	case protoreflect.StringKind:
+		checkString(v.String(), rules.GetString_(), path, errs)
+	case protoreflect.EnumKind:
+		checkEnum(fd.Enum(), v.Enum(), rules.GetEnum(), path, errs)
+	}
+}
+
+func checkInt(v int64, rules *IntRules, path string, errs *Error) {
+	if rules == nil {
+		return
+	}
+
+	switch {
+	case rules.Gt != nil && v <= rules.GetGt():
+		errs.add(path, "must be greater than %d", rules.GetGt())
+	case rules.Gte != nil && v < rules.GetGte():
+		errs.add(path, "must be at least %d", rules.GetGte())
+	case rules.Lt != nil && v >= rules.GetLt():
+		errs.add(path, "must be less than %d", rules.GetLt())
+	case rules.Lte != nil && v > rules.GetLte():
+		errs.add(path, "must be at most %d", rules.GetLte())
+	}
+}
+
+func checkString(v string, rules *StringRules, path string, errs *Error) {
+	if rules == nil {
+		return
+	}
+
+	n := uint64(utf8.RuneCountInString(v))
+
+	switch {
+	case rules.MinLen != nil && n < rules.GetMinLen():
+		errs.add(path, "must be at least %d characters", rules.GetMinLen())
+	case rules.MaxLen != nil && n > rules.GetMaxLen():
+		errs.add(path, "must be at most %d characters", rules.GetMaxLen())
+	}
+}
+
+func checkEnum(ed protoreflect.EnumDescriptor, v protoreflect.EnumNumber, rules *EnumRules, path string, errs *Error) {
+	if rules == nil {
+		return
+	}
+
+	switch {
+	case rules.GetDefinedOnly() && ed.Values().ByNumber(v) == nil:
+		errs.add(path, "must be one of the values of %s", ed.FullName())
+	case rules.GetNotUnspecified() && v == 0:
+		errs.add(path, "must be specified")
+	}
+}
+
+func (e *Error) add(path, format string, args ...interface{}) {
+	*e = append(*e, Violation{Field: path, Description: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,97 @@
+// External, as the racing messages exercising the rules import this package.
+package validate_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/proto/validate"
+)
+
+func TestMessage(t *testing.T) {
+	hidden := false
+
+	tests := []struct {
+		name string
+		in   proto.Message
+		want []string
+	}{
+		{
+			name: "valid",
+			in: &racing.ListRacesRequest{
+				Filter:   &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 2}, Visible: &hidden},
+				OrderBy:  "name",
+				PageSize: 1000,
+			},
+		},
+		{
+			name: "empty",
+			in:   &racing.ListRacesRequest{},
+		},
+		{
+			name: "without rules",
+			in:   &racing.GetRaceStatsResponse{Groups: []*racing.RaceStatsGroup{{Count: -1}}},
+		},
+		{
+			name: "int",
+			in:   &racing.ListRacesRequest{PageSize: 1001},
+			want: []string{"page_size: must be at most 1000"},
+		},
+		{
+			name: "string",
+			in:   &racing.ListRacesRequest{OrderBy: strings.Repeat("x", 201)},
+			want: []string{"order_by: must be at most 200 characters"},
+		},
+		{
+			name: "nested and repeated",
+			in:   &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1, -1, 1}}},
+			want: []string{"filter.meeting_ids[1]: must be greater than 0", "filter.meeting_ids[2]: must not repeat an earlier item"},
+		},
+		{
+			name: "too many items",
+			in:   &racing.ListNextToJumpRequest{MeetingIds: make([]int64, 101)},
+			want: []string{"meeting_ids: must hold at most 100 items"},
+		},
+		{
+			name: "implicit presence",
+			in:   &racing.GetRaceRequest{},
+			want: []string{"id: must be greater than 0"},
+		},
+		{
+			name: "enum",
+			in: &racing.GetRaceStatsRequest{GroupBy: []racing.RaceStatsGroupBy{
+				racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_STATUS, 0, 99,
+			}},
+			want: []string{"group_by[1]: must be specified", "group_by[2]: must be one of the values of racing.RaceStatsGroupBy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate.Message(tt.in)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Message() error = %v, want nil", err)
+				}
+				return
+			}
+
+			violations, ok := err.(validate.Error)
+			if !ok {
+				t.Fatalf("Message() error = %v, want validate.Error", err)
+			}
+
+			var got []string
+			for _, v := range violations {
+				got = append(got, v.Field+": "+v.Description)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Message() violations = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
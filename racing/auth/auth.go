@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+)
+
+// Roles granted to callers through the roles claim of their token.
+const (
+	// RolePublic is required by RPCs anyone may call, with or without a token.
+	RolePublic = "public"
+	// RoleTrader is required by RPCs that change races.
+	RoleTrader = "trader"
+	// RoleInternal allows callers to see races hidden from customers.
+	RoleInternal = "internal"
+)
+
+// authorizationKey is the gRPC metadata key the gateway forwards the Authorization header under.
+const authorizationKey = "authorization"
+
+// Policy maps full RPC method names (e.g. "/racing.Racing/ListRaces") to the
+// role required to call them. Methods missing from the policy require
+// RoleTrader, so new RPCs are protected until they're explicitly opened up.
+type Policy map[string]string
+
+// Role returns the role required to call method.
+func (p Policy) Role(method string) string {
+	if role, ok := p[method]; ok {
+		return role
+	}
+
+	return RoleTrader
+}
+
+// Claims identifies an authenticated caller.
+type Claims struct {
+	Subject string
+	Roles   []string
+}
+
+// HasRole reports whether the caller has been granted role.
+func (c *Claims) HasRole(role string) bool {
+	if role == RolePublic {
+		return true
+	}
+
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+type claimsKey struct{}
+
+// FromContext returns the claims of the caller making the request, which are
+// empty for anonymous callers.
+func FromContext(ctx context.Context) *Claims {
+	if claims, ok := ctx.Value(claimsKey{}).(*Claims); ok {
+		return claims
+	}
+
+	return &Claims{}
+}
+
+// HasRole reports whether the caller making the request has been granted role.
+func HasRole(ctx context.Context, role string) bool {
+	return FromContext(ctx).HasRole(role)
+}
+
+// Authorizer authenticates callers from their bearer token and checks they
+// hold the role the policy requires for each RPC.
+type Authorizer struct {
+	verifier *jwtauth.Verifier
+	policy   Policy
+}
+
+// NewAuthorizer creates an authorizer enforcing policy. When verifier is nil
+// authentication is disabled and every caller is trusted with every role, as
+// it was before authentication was introduced.
+func NewAuthorizer(verifier *jwtauth.Verifier, policy Policy) *Authorizer {
+	return &Authorizer{verifier: verifier, policy: policy}
+}
+
+// UnaryServerInterceptor authorizes every unary RPC, attaching the caller's claims to its context.
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authorizes every streaming RPC, attaching the caller's claims to its context.
+func (a *Authorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Authorizer) authorize(ctx context.Context, method string) (context.Context, error) {
+	if a.verifier == nil {
+		return context.WithValue(ctx, claimsKey{}, &Claims{Roles: []string{RoleTrader, RoleInternal}}), nil
+	}
+
+	claims := &Claims{}
+
+	// A token that is present must be valid, even for public RPCs, so callers
+	// find out about bad credentials rather than silently losing access.
+	if token, ok := bearerToken(ctx); ok {
+		verified, err := a.verifier.Verify(token)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid bearer token: %s", err)
+		}
+		claims = &Claims{Subject: verified.Subject, Roles: verified.Roles}
+	}
+
+	if role := a.policy.Role(method); !claims.HasRole(role) {
+		if claims.Subject == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+
+		return nil, status.Errorf(codes.PermissionDenied, "%s requires the %s role", method, role)
+	}
+
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+// bearerToken returns the token from the caller's authorization metadata, if any.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	for _, value := range md.Get(authorizationKey) {
+		if len(value) > len("bearer ") && strings.EqualFold(value[:len("bearer ")], "bearer ") {
+			return value[len("bearer "):], true
+		}
+	}
+
+	return "", false
+}
+
+// serverStream overrides the context of a wrapped stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
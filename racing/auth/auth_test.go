@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+	"git.neds.sh/matty/entain/pkg/jwtauth/jwtauthtest"
+)
+
+var secret = []byte("test-secret")
+
+func TestAuthorizer(t *testing.T) {
+	policy := Policy{"/racing.Racing/ListRaces": RolePublic}
+	expiry := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		verifier     *jwtauth.Verifier
+		method       string
+		token        string
+		wantCode     codes.Code
+		wantInternal bool
+	}{
+		{
+			name:     "public without token",
+			verifier: jwtauth.NewVerifier(secret, nil),
+			method:   "/racing.Racing/ListRaces",
+		},
+		{
+			name:     "public with invalid token",
+			verifier: jwtauth.NewVerifier(secret, nil),
+			method:   "/racing.Racing/ListRaces",
+			token:    "nope",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:         "public as internal",
+			verifier:     jwtauth.NewVerifier(secret, nil),
+			method:       "/racing.Racing/ListRaces",
+			token:        jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "ops", expiry, RoleInternal),
+			wantInternal: true,
+		},
+		{
+			name:     "unlisted without token",
+			verifier: jwtauth.NewVerifier(secret, nil),
+			method:   "/racing.Racing/UpdateRace",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "unlisted without trader role",
+			verifier: jwtauth.NewVerifier(secret, nil),
+			method:   "/racing.Racing/UpdateRace",
+			token:    jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "punter", expiry),
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "unlisted as trader",
+			verifier: jwtauth.NewVerifier(secret, nil),
+			method:   "/racing.Racing/UpdateRace",
+			token:    jwtauthtest.Sign(t, jwt.SigningMethodHS256, secret, "", "trader", expiry, RoleTrader),
+		},
+		{
+			name:         "disabled",
+			method:       "/racing.Racing/UpdateRace",
+			wantInternal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tt.token))
+			}
+
+			var internal bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				internal = HasRole(ctx, RoleInternal)
+				return nil, nil
+			}
+
+			_, err := NewAuthorizer(tt.verifier, policy).UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("interceptor code = %s, want %s (%v)", code, tt.wantCode, err)
+			}
+			if internal != tt.wantInternal {
+				t.Errorf("HasRole(internal) = %v, want %v", internal, tt.wantInternal)
+			}
+		})
+	}
+}
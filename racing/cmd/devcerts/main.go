@@ -0,0 +1,31 @@
+// Command devcerts generates a local CA plus server and client certificates
+// for running the racing service and api gateway over (mutual) TLS in development.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"git.neds.sh/matty/entain/pkg/tlsutil"
+)
+
+var (
+	dir   = flag.String("dir", "./certs", "Directory to write the certificates to")
+	hosts = flag.String("hosts", "localhost,127.0.0.1", "Comma separated DNS names and IPs the server certificate is valid for")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0700); err != nil {
+		log.Fatalf("failed creating %s: %s", *dir, err)
+	}
+
+	if err := tlsutil.GenerateDevCerts(*dir, strings.Split(*hosts, ",")); err != nil {
+		log.Fatalf("failed generating certificates: %s", err)
+	}
+
+	log.Printf("wrote development certificates to %s", *dir)
+}
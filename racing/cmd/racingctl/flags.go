@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// messageFlags adds a flag to fs for each scalar or timestamp field of msg,
+// named after the field, e.g. -meeting-ids for meeting_ids, which sets the
+// field when given. Repeated fields take comma separated values, and
+// timestamps RFC 3339 times. As the flags come from the descriptor, they
+// always match the message's current fields.
+func messageFlags(fs *flag.FlagSet, msg proto.Message) {
+	m := msg.ProtoReflect()
+
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.IsMap() || field.Kind() == protoreflect.GroupKind {
+			continue
+		}
+		if field.Kind() == protoreflect.MessageKind && (field.IsList() || !isTimestamp(field)) {
+			continue
+		}
+
+		usage := fmt.Sprintf("Only races with this %s (%s)", field.Name(), field.Kind())
+		switch {
+		case isTimestamp(field):
+			usage = fmt.Sprintf("Only races with this %s (RFC 3339 time)", field.Name())
+		case field.IsList():
+			usage = fmt.Sprintf("Only races with one of these %s (comma separated %ss)", field.Name(), field.Kind())
+		}
+
+		fs.Var(&fieldValue{msg: m, field: field}, strings.ReplaceAll(string(field.Name()), "_", "-"), usage)
+	}
+}
+
+// fieldValue is a flag.Value setting a field of a message.
+type fieldValue struct {
+	msg   protoreflect.Message
+	field protoreflect.FieldDescriptor
+}
+
+func (f *fieldValue) String() string {
+	// The flag package calls String on a zero fieldValue for usage messages.
+	if f.msg == nil || !f.msg.Has(f.field) {
+		return ""
+	}
+
+	if !f.field.IsList() {
+		return formatScalar(f.field, f.msg.Get(f.field))
+	}
+
+	list := f.msg.Get(f.field).List()
+
+	values := make([]string, list.Len())
+	for i := range values {
+		values[i] = formatScalar(f.field, list.Get(i))
+	}
+
+	return strings.Join(values, ",")
+}
+
+func (f *fieldValue) Set(s string) error {
+	if !f.field.IsList() {
+		value, err := parseScalar(f.field, s)
+		if err != nil {
+			return err
+		}
+
+		f.msg.Set(f.field, value)
+
+		return nil
+	}
+
+	list := f.msg.Mutable(f.field).List()
+	for _, part := range strings.Split(s, ",") {
+		value, err := parseScalar(f.field, strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+
+		list.Append(value)
+	}
+
+	return nil
+}
+
+// IsBoolFlag lets a bool field be set to true by its flag alone, e.g. -visible.
+func (f *fieldValue) IsBoolFlag() bool {
+	return f.field != nil && f.field.Kind() == protoreflect.BoolKind && !f.field.IsList()
+}
+
+// isTimestamp reports whether field holds a google.protobuf.Timestamp.
+func isTimestamp(field protoreflect.FieldDescriptor) bool {
+	return field.Kind() == protoreflect.MessageKind && field.Message().FullName() == timestampName
+}
+
+// timestampName is the full name of google.protobuf.Timestamp.
+var timestampName = (&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()
+
+// parseScalar parses s as a value of field's kind. Enums are given by name,
+// and timestamps as RFC 3339 times.
+func parseScalar(field protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	if isTimestamp(field) {
+		t, err := time.Parse(time.RFC3339, s)
+		return protoreflect.ValueOfMessage(timestamppb.New(t).ProtoReflect()), err
+	}
+
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.EnumKind:
+		value := field.Enum().Values().ByName(protoreflect.Name(s))
+		if value == nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown %s %q", field.Enum().Name(), s)
+		}
+		return protoreflect.ValueOfEnum(value.Number()), nil
+	}
+
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %s", field.Kind())
+}
+
+func formatScalar(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	if isTimestamp(field) {
+		return value.Message().Interface().(*timestamppb.Timestamp).AsTime().Format(time.RFC3339)
+	}
+
+	if field.Kind() == protoreflect.EnumKind {
+		if v := field.Enum().Values().ByNumber(value.Enum()); v != nil {
+			return string(v.Name())
+		}
+	}
+
+	return value.String()
+}
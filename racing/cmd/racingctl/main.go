@@ -0,0 +1,250 @@
+// Command racingctl lists, gets and watches races on a racing server, e.g.
+//
+//	racingctl list -meeting-ids 1,2 -visible -order-by "advertised_start_time desc"
+//	racingctl get -output json 42
+//	racingctl watch -meeting-ids 5 -interval 10s
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"git.neds.sh/matty/entain/pkg/tlsutil"
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+const usage = `Usage: racingctl <command> [flags] [args]
+
+Commands:
+  list    List races matching the filter flags
+  get     Get a race by its ID
+  watch   Poll for races matching the filter flags, printing them as they're
+          added, changed or removed
+
+Run racingctl <command> -h for the command's flags.
+`
+
+var commands = map[string]func(ctx context.Context, args []string, out io.Writer) error{
+	"list":  list,
+	"get":   get,
+	"watch": watch,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("racingctl: ")
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := command(ctx, os.Args[2:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// options are the flags shared by every command.
+type options struct {
+	addr       string
+	token      string
+	output     string
+	timeout    time.Duration
+	tls        bool
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet("racingctl "+name, flag.ContinueOnError)
+	opts := &options{}
+
+	fs.StringVar(&opts.addr, "addr", "localhost:9000", "Racing gRPC server address")
+	fs.StringVar(&opts.token, "token", os.Getenv("RACINGCTL_TOKEN"), "Bearer token to call with, defaulting to $RACINGCTL_TOKEN")
+	fs.StringVar(&opts.output, "output", outputTable, "Output format: table or json")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "Timeout of each RPC")
+	fs.BoolVar(&opts.tls, "tls", false, "Connect over TLS")
+	fs.StringVar(&opts.caFile, "ca-file", "", "PEM CA bundle to verify the server with, instead of the system roots")
+	fs.StringVar(&opts.certFile, "cert-file", "", "PEM client certificate for mutual TLS")
+	fs.StringVar(&opts.keyFile, "key-file", "", "PEM private key for cert-file")
+	fs.StringVar(&opts.serverName, "server-name", "", "Name to verify the server certificate against, defaulting to the addr host")
+
+	return fs, opts
+}
+
+// dial connects to the racing server, returning its client and the connection
+// to close when done.
+func (o *options) dial() (racing.RacingClient, *grpc.ClientConn, error) {
+	if o.output != outputTable && o.output != outputJSON {
+		return nil, nil, fmt.Errorf("unknown output format %q", o.output)
+	}
+
+	creds, err := o.credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := grpc.Dial(o.addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return racing.NewRacingClient(conn), conn, nil
+}
+
+func (o *options) credentials() (credentials.TransportCredentials, error) {
+	if !o.tls {
+		return insecure.NewCredentials(), nil
+	}
+
+	var (
+		rootCAs *tlsutil.CAPool
+		keyPair *tlsutil.KeyPair
+		err     error
+	)
+
+	if o.caFile != "" {
+		if rootCAs, err = tlsutil.LoadCAPool(o.caFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.certFile != "" {
+		if keyPair, err = tlsutil.LoadKeyPair(o.certFile, o.keyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	serverName := o.serverName
+	if serverName == "" {
+		if serverName, _, err = net.SplitHostPort(o.addr); err != nil {
+			return nil, err
+		}
+	}
+
+	return credentials.NewTLS(tlsutil.ClientConfig(rootCAs, keyPair, serverName)), nil
+}
+
+// rpcContext returns the context to make a single RPC with, carrying the
+// bearer token and timeout.
+func (o *options) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+o.token)
+	}
+
+	return context.WithTimeout(ctx, o.timeout)
+}
+
+func list(ctx context.Context, args []string, out io.Writer) error {
+	fs, opts := newFlagSet("list")
+
+	in := &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{}}
+	messageFlags(fs, in.Filter)
+	fs.StringVar(&in.OrderBy, "order-by", "", `Fields to order by, e.g. "advertised_start_time desc, name"`)
+	pageSize := fs.Int("page-size", 0, "Maximum number of races to list, or all when 0")
+	fs.StringVar(&in.PageToken, "page-token", "", "Page token printed by a previous list, to list the following page")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	in.PageSize = int32(*pageSize)
+
+	client, conn, err := opts.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := opts.rpcContext(ctx)
+	defer cancel()
+
+	resp, err := client.ListRaces(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	if opts.output == outputJSON {
+		return writeJSON(out, resp)
+	}
+
+	if err := writeTable(out, resp.Races, "", nil); err != nil {
+		return err
+	}
+
+	if resp.NextPageToken != "" {
+		fmt.Fprintf(os.Stderr, "next page: -page-token %s\n", resp.NextPageToken)
+	}
+
+	return nil
+}
+
+func get(ctx context.Context, args []string, out io.Writer) error {
+	fs, opts := newFlagSet("get")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: racingctl get [flags] <id>\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("get takes a single race ID")
+	}
+
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid race ID %q", fs.Arg(0))
+	}
+
+	client, conn, err := opts.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := opts.rpcContext(ctx)
+	defer cancel()
+
+	race, err := client.GetRace(ctx, &racing.GetRaceRequest{Id: id})
+	if err != nil {
+		return err
+	}
+
+	if opts.output == outputJSON {
+		return writeJSON(out, race)
+	}
+
+	return writeTable(out, []*racing.Race{race}, "", nil)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// writeJSON writes msg as a single line of JSON, in the gateway's encoding.
+func writeJSON(w io.Writer, msg proto.Message) error {
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// Compact, as protojson's spacing isn't stable between builds.
+	var line json.RawMessage = b
+	b, err = json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", b)
+
+	return err
+}
+
+// writeTable writes races as an aligned table, prefixing each row with the
+// matching entry of prefixes (under prefixHeader) when given.
+func writeTable(w io.Writer, races []*racing.Race, prefixHeader string, prefixes []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	header := "ID\tMEETING\tNUMBER\tNAME\tVISIBLE\tSTART"
+	if prefixHeader != "" {
+		header = prefixHeader + "\t" + header
+	}
+	fmt.Fprintln(tw, header)
+
+	for i, race := range races {
+		if prefixes != nil {
+			fmt.Fprintf(tw, "%s\t", prefixes[i])
+		}
+
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%t\t%s\n",
+			race.Id, race.MeetingId, race.Number, race.Name, race.Visible,
+			race.AdvertisedStartTime.AsTime().Local().Format(time.RFC3339))
+	}
+
+	return tw.Flush()
+}
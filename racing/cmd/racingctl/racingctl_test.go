@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+func TestMessageFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    *racing.ListRacesRequestFilter
+		wantErr bool
+	}{
+		{
+			name: "none",
+			want: &racing.ListRacesRequestFilter{},
+		},
+		{
+			name: "repeated",
+			args: []string{"-meeting-ids", "1, 2", "-meeting-ids", "3"},
+			want: &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 2, 3}},
+		},
+		{
+			name: "bool alone",
+			args: []string{"-visible"},
+			want: &racing.ListRacesRequestFilter{Visible: proto.Bool(true)},
+		},
+		{
+			name: "optional false",
+			args: []string{"-visible=false"},
+			want: &racing.ListRacesRequestFilter{Visible: proto.Bool(false)},
+		},
+		{
+			name: "timestamp",
+			args: []string{"-advertised-start-time-from", "2021-03-01T20:00:00+10:00"},
+			want: &racing.ListRacesRequestFilter{AdvertisedStartTimeFrom: timestamppb.New(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC))},
+		},
+		{
+			name:    "invalid",
+			args:    []string{"-meeting-ids", "one"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timestamp",
+			args:    []string{"-advertised-start-time-from", "tomorrow"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+
+			got := &racing.ListRacesRequestFilter{}
+			messageFlags(fs, got)
+
+			err := fs.Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !proto.Equal(got, tt.want) {
+				t.Errorf("filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffRaces(t *testing.T) {
+	first := []*racing.Race{{Id: 1, Name: "One"}, {Id: 2, Name: "Two"}}
+	second := []*racing.Race{{Id: 3, Name: "Three"}, {Id: 2, Name: "Two", Visible: true}}
+
+	events, seen := diffRaces(nil, first)
+	if got := eventSummary(events); !reflect.DeepEqual(got, []string{"ADDED 1", "ADDED 2"}) {
+		t.Errorf("first diffRaces() = %v", got)
+	}
+
+	events, _ = diffRaces(seen, second)
+	if got := eventSummary(events); !reflect.DeepEqual(got, []string{"REMOVED 1", "CHANGED 2", "ADDED 3"}) {
+		t.Errorf("second diffRaces() = %v", got)
+	}
+
+	if events, _ = diffRaces(seen, first); len(events) != 0 {
+		t.Errorf("unchanged diffRaces() = %v, want none", eventSummary(events))
+	}
+}
+
+func eventSummary(events []event) []string {
+	var summary []string
+	for _, e := range events {
+		summary = append(summary, e.Type+" "+strconv.FormatInt(e.Race.Id, 10))
+	}
+	return summary
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// Watch event types.
+const (
+	eventAdded   = "ADDED"
+	eventChanged = "CHANGED"
+	eventRemoved = "REMOVED"
+)
+
+// event is a change to a watched race.
+type event struct {
+	Type string
+	Race *racing.Race
+}
+
+// watch polls ListRaces, as the racing service has no streaming RPC to watch
+// races with, and prints the races added, changed or removed since the last
+// poll. The first poll reports every matching race as added.
+func watch(ctx context.Context, args []string, out io.Writer) error {
+	fs, opts := newFlagSet("watch")
+
+	in := &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{}}
+	messageFlags(fs, in.Filter)
+	interval := fs.Duration("interval", 5*time.Second, "Time between polls")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, conn, err := opts.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var seen map[int64]*racing.Race
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		rpcCtx, cancel := opts.rpcContext(ctx)
+		resp, err := client.ListRaces(rpcCtx, in)
+		cancel()
+
+		// Keep watching through errors, which are likely to be transient.
+		if err != nil && ctx.Err() == nil {
+			log.Printf("failed listing races: %s", err)
+		}
+
+		if err == nil {
+			var events []event
+			events, seen = diffRaces(seen, resp.Races)
+
+			if err := writeEvents(out, opts.output, events); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// diffRaces returns the events turning the races previously seen into races,
+// ordered by race ID, along with races indexed for the next diff.
+func diffRaces(seen map[int64]*racing.Race, races []*racing.Race) ([]event, map[int64]*racing.Race) {
+	var events []event
+
+	current := make(map[int64]*racing.Race, len(races))
+	for _, race := range races {
+		current[race.Id] = race
+
+		previous, ok := seen[race.Id]
+		switch {
+		case !ok:
+			events = append(events, event{eventAdded, race})
+		case !proto.Equal(previous, race):
+			events = append(events, event{eventChanged, race})
+		}
+	}
+
+	for id, race := range seen {
+		if _, ok := current[id]; !ok {
+			events = append(events, event{eventRemoved, race})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Race.Id < events[j].Race.Id
+	})
+
+	return events, current
+}
+
+func writeEvents(w io.Writer, output string, events []event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if output == outputTable {
+		races := make([]*racing.Race, len(events))
+		types := make([]string, len(events))
+		for i, e := range events {
+			races[i], types[i] = e.Race, e.Type
+		}
+
+		return writeTable(w, races, "EVENT", types)
+	}
+
+	for _, e := range events {
+		race, err := protojson.Marshal(e.Race)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(struct {
+			Type string          `json:"type"`
+			Race json.RawMessage `json:"race"`
+		}{e.Type, race})
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\n", b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
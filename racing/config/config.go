@@ -0,0 +1,116 @@
+package config
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/grpcserver/deadline"
+	"git.neds.sh/matty/entain/pkg/settings"
+)
+
+// EnvPrefix prefixes the environment variable of every setting, e.g. RACING_GRPC_ENDPOINT.
+const EnvPrefix = "RACING"
+
+// Config holds the racing service's configuration.
+type Config struct {
+	GRPCEndpoint    string
+	GRPCReflection  bool
+	MetricsEndpoint string
+
+	DBPath string
+	DBSeed bool
+
+	NextToJumpRefresh time.Duration
+
+	CacheEnabled bool
+	CacheSize    int
+	CacheTTL     time.Duration
+
+	settings.ServerTLS
+
+	AuthHMACSecret string
+	AuthJWKSFile   string
+
+	settings.RPCTimeouts
+	RPCMethodTimeouts string
+
+	HealthInterval  time.Duration
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+
+	settings.Telemetry
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		GRPCEndpoint:      "localhost:9000",
+		MetricsEndpoint:   "localhost:9100",
+		DBPath:            "./db/racing.db",
+		DBSeed:            true,
+		NextToJumpRefresh: 30 * time.Second,
+		CacheSize:         1000,
+		CacheTTL:          5 * time.Second,
+		RPCTimeouts:       settings.DefaultRPCTimeouts(),
+		HealthInterval:    5 * time.Second,
+		ShutdownTimeout:   15 * time.Second,
+		Telemetry:         settings.DefaultTelemetry(),
+	}
+}
+
+// Settings returns the settings of c, each reading and writing its field.
+func (c *Config) Settings() []settings.Setting {
+	s := []settings.Setting{
+		{Name: "grpc-endpoint", Usage: "gRPC server endpoint", Value: settings.String(&c.GRPCEndpoint)},
+		{Name: "grpc-reflection", Usage: "Serve gRPC server reflection, so clients such as grpcurl can call RPCs without the protos", Value: settings.Bool(&c.GRPCReflection)},
+		{Name: "metrics-endpoint", Usage: "Prometheus metrics endpoint", Value: settings.String(&c.MetricsEndpoint)},
+		{Name: "db-path", Usage: "Path to the SQLite races database", Value: settings.String(&c.DBPath)},
+		{Name: "db-seed", Usage: "Seed the database with dummy races on start up", Value: settings.Bool(&c.DBSeed)},
+		{Name: "next-to-jump-refresh", Usage: "Interval between next to jump index reloads, which pick up races changed other than through the racing service", Value: settings.Duration(&c.NextToJumpRefresh)},
+		{Name: "cache-enabled", Usage: "Cache race listings in memory", Value: settings.Bool(&c.CacheEnabled)},
+		{Name: "cache-size", Usage: "Maximum number of cached race listings", Value: settings.Int(&c.CacheSize)},
+		{Name: "cache-ttl", Usage: "How long a cached race listing is served for", Value: settings.Duration(&c.CacheTTL)},
+		{Name: "auth-hmac-secret", Usage: "Shared secret verifying HS256 bearer tokens; authentication is disabled unless this or auth-jwks-file is set", Secret: true, Value: settings.String(&c.AuthHMACSecret)},
+		{Name: "auth-jwks-file", Usage: "JWKS file of RSA public keys verifying RS256 bearer tokens", Value: settings.String(&c.AuthJWKSFile)},
+		{Name: "rpc-method-timeouts", Usage: "Per-method default and optional max timeouts, e.g. /racing.Racing/GetRaceStats=20s/1m", Value: settings.String(&c.RPCMethodTimeouts)},
+		{Name: "health-interval", Usage: "Interval between database health checks", Value: settings.Duration(&c.HealthInterval)},
+		{Name: "shutdown-delay", Usage: "Time to keep serving with failing readiness before draining on shutdown", Value: settings.Duration(&c.ShutdownDelay)},
+		{Name: "shutdown-timeout", Usage: "Time allowed for in-flight requests to drain on shutdown", Value: settings.Duration(&c.ShutdownTimeout)},
+	}
+	s = append(s, c.ServerTLS.Settings()...)
+	s = append(s, c.RPCTimeouts.Settings()...)
+
+	return append(s, c.Telemetry.Settings()...)
+}
+
+// Validate checks the configuration is usable, reporting every problem found.
+func (c *Config) Validate() error {
+	var p settings.Problems
+
+	p.Endpoint("grpc-endpoint", c.GRPCEndpoint)
+	p.Endpoint("metrics-endpoint", c.MetricsEndpoint)
+
+	if c.DBPath == "" {
+		p.Addf("db-path", "must be set")
+	}
+
+	p.Positive("next-to-jump-refresh", c.NextToJumpRefresh)
+	p.Positive("health-interval", c.HealthInterval)
+	p.Positive("shutdown-timeout", c.ShutdownTimeout)
+	p.NotNegative("shutdown-delay", c.ShutdownDelay)
+
+	_, err := deadline.ParseMethodTimeouts(c.RPCMethodTimeouts)
+	p.Check("rpc-method-timeouts", err)
+
+	if c.CacheEnabled {
+		if c.CacheSize <= 0 {
+			p.Addf("cache-size", "must be positive")
+		}
+		p.Positive("cache-ttl", c.CacheTTL)
+	}
+
+	c.ServerTLS.Check(&p)
+	c.RPCTimeouts.Check(&p)
+	c.Telemetry.Check(&p)
+
+	return p.Err()
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/settings"
+)
+
+// load builds a Config from args the way main does.
+func load(args []string) (*Config, bool, error) {
+	cfg := Default()
+	printConfig, err := settings.LoadConfig(args, EnvPrefix, cfg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return cfg, printConfig, nil
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "racing.yaml")
+	if err := ioutil.WriteFile(path, []byte("db-path: /data/file.db\ncache-ttl: 1m\ncache-size: 10\nlog-level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("RACING_CACHE_SIZE", "20")
+	os.Setenv("RACING_LOG_LEVEL", "warn")
+	defer os.Unsetenv("RACING_CACHE_SIZE")
+	defer os.Unsetenv("RACING_LOG_LEVEL")
+
+	cfg, printConfig, err := load([]string{"racing", "--config", path, "--log-level", "error", "--grpc-endpoint", ":9001"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if printConfig {
+		t.Errorf("Load() printConfig = true, want false")
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default", cfg.MetricsEndpoint, "localhost:9100"},
+		{"file", cfg.DBPath, "/data/file.db"},
+		{"file", cfg.CacheTTL, time.Minute},
+		{"env over file", cfg.CacheSize, 20},
+		{"flag over env", cfg.LogLevel, "error"},
+		{"flag", cfg.GRPCEndpoint, ":9001"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoad_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "unknown setting in file",
+			file:    "db-paht: x\n",
+			wantErr: `unknown setting "db-paht"`,
+		},
+		{
+			name:    "invalid flag value",
+			args:    []string{"--cache-ttl", "soon"},
+			wantErr: "invalid value",
+		},
+		{
+			name:    "validation",
+			args:    []string{"--grpc-endpoint", "nope", "--cache-enabled", "--cache-size", "0"},
+			wantErr: "invalid config: cache-size: must be positive; grpc-endpoint:",
+		},
+		{
+			name:    "client ca without certificate",
+			args:    []string{"--tls-client-ca-file", "ca.pem"},
+			wantErr: "tls-client-ca-file: requires tls-cert-file",
+		},
+		{
+			name:    "default timeout over max",
+			args:    []string{"--rpc-default-timeout", "1m", "--rpc-max-timeout", "30s"},
+			wantErr: "rpc-default-timeout: must not exceed rpc-max-timeout",
+		},
+		{
+			name:    "method timeout without method",
+			args:    []string{"--rpc-method-timeouts", "ListRaces=2s"},
+			wantErr: "rpc-method-timeouts: method timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"racing"}, tt.args...)
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "racing.yaml")
+				if err := ioutil.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "--config", path)
+			}
+
+			_, _, err := load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var buf bytes.Buffer
+	if err := settings.Print(&buf, Default()); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	// The printed config must load back to the same values.
+	path := filepath.Join(t.TempDir(), "racing.yaml")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := load([]string{"racing", "--config", path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if *cfg != *Default() {
+		t.Errorf("Load() = %+v, want %+v", cfg, Default())
+	}
+}
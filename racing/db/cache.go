@@ -0,0 +1,229 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// CachedRacesRepo is a RacesRepo that serves race listings from a read-through cache.
+type CachedRacesRepo interface {
+	RacesRepo
+
+	// CacheStats will return the cache's hit and miss counts so far.
+	CacheStats() CacheStats
+}
+
+// CacheStats holds counters describing cache effectiveness.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// sharedQueryTimeout bounds a query shared between concurrent lookups, which
+// runs independently of any one caller's context.
+const sharedQueryTimeout = 10 * time.Second
+
+type cachedRacesRepo struct {
+	// Accessed atomically, so kept first for 64-bit alignment.
+	hits   uint64
+	misses uint64
+
+	RacesRepo
+
+	size         int
+	ttl          time.Duration
+	queryTimeout time.Duration
+	now          func() time.Time
+	group        singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// generation is bumped on every write, so lookups that started before a
+	// write don't repopulate the cache with stale results.
+	generation uint64
+}
+
+// cacheEntry is a cached List result, held in LRU order.
+type cacheEntry struct {
+	key     string
+	races   []*racing.Race
+	expires time.Time
+}
+
+// NewCachedRacesRepo wraps repo with a cache of up to size List results, each
+// kept for at most ttl. Identical concurrent lookups share a single query, and
+// any write through the repository invalidates the whole cache.
+//
+// Races returned from the cache are shared between callers and must not be modified.
+func NewCachedRacesRepo(repo RacesRepo, size int, ttl time.Duration) CachedRacesRepo {
+	return &cachedRacesRepo{
+		RacesRepo:    repo,
+		size:         size,
+		ttl:          ttl,
+		queryTimeout: sharedQueryTimeout,
+		now:          time.Now,
+		entries:      make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+}
+
+func (c *cachedRacesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, opts ListOptions) ([]*racing.Race, error) {
+	key, err := cacheKey(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if races, ok := c.get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return races, nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	// Lookups only share a query within the same generation, so callers
+	// arriving after a write never receive results loaded before it.
+	generation := c.currentGeneration()
+	flightKey := strconv.FormatUint(generation, 10) + ":" + key
+
+	// The shared query outlives whichever caller started it, so one caller
+	// cancelling doesn't fail everyone waiting on the same lookup. It keeps the
+	// starting caller's span so its query is still traced.
+	results := c.group.DoChan(flightKey, func() (interface{}, error) {
+		queryCtx, cancel := context.WithTimeout(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx)), c.queryTimeout)
+		defer cancel()
+
+		races, err := c.RacesRepo.List(queryCtx, filter, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		c.put(key, races, generation)
+
+		return races, nil
+	})
+
+	select {
+	case res := <-results:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		return res.Val.([]*racing.Race), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *cachedRacesRepo) Upsert(ctx context.Context, race *racing.Race) error {
+	if err := c.RacesRepo.Upsert(ctx, race); err != nil {
+		return err
+	}
+
+	c.invalidate()
+
+	return nil
+}
+
+func (c *cachedRacesRepo) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+// get returns the unexpired races cached under key, marking them recently used.
+func (c *cachedRacesRepo) get(key string) ([]*racing.Race, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return entry.races, true
+}
+
+// put caches races under key, evicting the least recently used entry when
+// full. Results loaded before the latest write are discarded.
+func (c *cachedRacesRepo) put(key string, races []*racing.Race, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation || c.size <= 0 {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		races:   races,
+		expires: c.now().Add(c.ttl),
+	})
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *cachedRacesRepo) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// invalidate drops every cached entry. A write can change which races match
+// any filter, so entries aren't invalidated selectively.
+func (c *cachedRacesRepo) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+func (c *cachedRacesRepo) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// cacheKey normalises filter and opts into a key shared by equivalent lookups.
+func cacheKey(filter *racing.ListRacesRequestFilter, opts ListOptions) (string, error) {
+	key := fmt.Sprintf("%v|", opts)
+
+	if filter == nil {
+		return key, nil
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(filter)
+	if err != nil {
+		return "", err
+	}
+
+	return key + string(b), nil
+}
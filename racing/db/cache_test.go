@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// countingRacesRepo counts List calls, optionally blocking them until release
+// is closed or their context is done.
+type countingRacesRepo struct {
+	RacesRepo
+
+	lists   int32
+	release chan struct{}
+}
+
+func (c *countingRacesRepo) List(ctx context.Context, _ *racing.ListRacesRequestFilter, _ ListOptions) ([]*racing.Race, error) {
+	atomic.AddInt32(&c.lists, 1)
+	if c.release != nil {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return []*racing.Race{{Id: 1}}, nil
+}
+
+func (c *countingRacesRepo) Upsert(context.Context, *racing.Race) error { return nil }
+
+func Test_cachedRacesRepo_List(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	repo := &countingRacesRepo{}
+	cache := NewCachedRacesRepo(repo, 2, time.Minute).(*cachedRacesRepo)
+	cache.now = func() time.Time { return now }
+
+	list := func(meetingID int64) {
+		t.Helper()
+		if _, err := cache.List(context.Background(), &racing.ListRacesRequestFilter{MeetingIds: []int64{meetingID}}, ListOptions{}); err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+	}
+	expectQueries := func(want int32) {
+		t.Helper()
+		if got := atomic.LoadInt32(&repo.lists); got != want {
+			t.Fatalf("repository queried %d times, want %d", got, want)
+		}
+	}
+
+	list(1)
+	list(1)
+	expectQueries(1)
+
+	if got := cache.CacheStats(); got != (CacheStats{Hits: 1, Misses: 1}) {
+		t.Errorf("CacheStats() = %+v, want 1 hit and 1 miss", got)
+	}
+
+	// Filling beyond capacity evicts the least recently used filter.
+	list(2)
+	list(1)
+	list(3)
+	expectQueries(3)
+	list(1)
+	expectQueries(3)
+	list(2)
+	expectQueries(4)
+
+	// Entries expire after their TTL.
+	now = now.Add(time.Minute)
+	list(2)
+	expectQueries(5)
+
+	// Writes invalidate everything.
+	if err := cache.Upsert(context.Background(), &racing.Race{Id: 1}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	list(2)
+	expectQueries(6)
+}
+
+func Test_cachedRacesRepo_List_collapsesConcurrentQueries(t *testing.T) {
+	repo := &countingRacesRepo{release: make(chan struct{})}
+	cache := NewCachedRacesRepo(repo, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.List(context.Background(), nil, ListOptions{}); err != nil {
+				t.Errorf("List() error = %v", err)
+			}
+		}()
+	}
+
+	// Give the lookups time to queue up behind the first query.
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&repo.lists); got != 1 {
+		t.Errorf("repository queried %d times, want 1", got)
+	}
+}
+
+func Test_cachedRacesRepo_List_leaderCancels(t *testing.T) {
+	repo := &countingRacesRepo{release: make(chan struct{})}
+	cache := NewCachedRacesRepo(repo, 10, time.Minute)
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := cache.List(leaderCtx, nil, ListOptions{})
+		leaderErr <- err
+	}()
+
+	// Let the leader start the shared query before the follower joins it.
+	time.Sleep(50 * time.Millisecond)
+
+	followerErr := make(chan error, 1)
+	go func() {
+		races, err := cache.List(context.Background(), nil, ListOptions{})
+		if err == nil && len(races) != 1 {
+			t.Errorf("List() returned %d races, want 1", len(races))
+		}
+		followerErr <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancelLeader()
+
+	if err := <-leaderErr; err != context.Canceled {
+		t.Errorf("leader List() error = %v, want %v", err, context.Canceled)
+	}
+
+	close(repo.release)
+
+	if err := <-followerErr; err != nil {
+		t.Errorf("follower List() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&repo.lists); got != 1 {
+		t.Errorf("repository queried %d times, want 1", got)
+	}
+}
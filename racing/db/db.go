@@ -1,19 +1,41 @@
 package db
 
 import (
+	"database/sql"
 	"time"
 
 	"syreclabs.com/go/faker"
 )
 
-func (r *racesRepo) seed() error {
-	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`)
+func (r *racesRepo) createSchema() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME, race_type INTEGER NOT NULL DEFAULT 0)`)
 	if err == nil {
 		_, err = statement.Exec()
 	}
+	if err != nil {
+		return err
+	}
+
+	// Databases created before races had a type are missing its column.
+	var hasRaceType bool
+	if err := r.db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('races') WHERE name = 'race_type'`).Scan(&hasRaceType); err != nil {
+		return err
+	}
+	if !hasRaceType {
+		_, err = r.db.Exec(`ALTER TABLE races ADD COLUMN race_type INTEGER NOT NULL DEFAULT 0`)
+	}
+
+	return err
+}
+
+func (r *racesRepo) seed() error {
+	var (
+		statement *sql.Stmt
+		err       error
+	)
 
 	for i := 1; i <= 100; i++ {
-		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?)`)
+		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time, race_type) VALUES (?,?,?,?,?,?,?)`)
 		if err == nil {
 			_, err = statement.Exec(
 				i,
@@ -22,6 +44,7 @@ func (r *racesRepo) seed() error {
 				faker.Number().Between(1, 12),
 				faker.Number().Between(0, 1),
 				faker.Time().Between(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
+				faker.Number().Between(1, 3),
 			)
 		}
 	}
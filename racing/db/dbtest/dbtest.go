@@ -0,0 +1,24 @@
+// Package dbtest opens in-memory SQLite databases for tests of code using them.
+package dbtest
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open returns an empty in-memory database, closed when the test ends.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// Every connection to :memory: opens its own database.
+	db.SetMaxOpenConns(1)
+
+	return db
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "racing_db_query_duration_seconds",
+		Help:    "Duration of races repository queries, including scanning results.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"query"})
+
+	rowsScanned = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "racing_db_rows_scanned",
+		Help:    "Number of rows scanned per races repository query.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"query"})
+)
+
+// observeQuery records the duration of the named query since start, and the
+// number of rows it scanned.
+func observeQuery(query string, start time.Time, rows int) {
+	queryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	rowsScanned.WithLabelValues(query).Observe(float64(rows))
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// orderColumns maps the race fields List can be ordered by onto their columns.
+var orderColumns = map[string]string{
+	"id":                    "id",
+	"meeting_id":            "meeting_id",
+	"name":                  "name",
+	"number":                "number",
+	"visible":               "visible",
+	"advertised_start_time": "datetime(advertised_start_time)",
+}
+
+// ListOptions controls the order and range of the races returned by List.
+type ListOptions struct {
+	// OrderBy sorts the races, ties (and an empty OrderBy) being broken by id.
+	OrderBy []Order
+	// Limit caps the number of races returned, when positive.
+	Limit int
+	// Offset skips this many races.
+	Offset int
+}
+
+// Order sorts races by a field.
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// ParseOrderBy parses an order by expression: a comma separated list of race
+// fields, each optionally followed by "asc" or "desc", e.g.
+// "advertised_start_time desc, name".
+func ParseOrderBy(s string) ([]Order, error) {
+	var orders []Order
+
+	if strings.TrimSpace(s) == "" {
+		return orders, nil
+	}
+
+	seen := make(map[string]bool)
+
+	for _, term := range strings.Split(s, ",") {
+		words := strings.Fields(term)
+		if len(words) == 0 || len(words) > 2 {
+			return nil, fmt.Errorf("invalid order by term %q", strings.TrimSpace(term))
+		}
+
+		order := Order{Field: words[0]}
+		if _, ok := orderColumns[order.Field]; !ok {
+			return nil, fmt.Errorf("unsupported order by field %q", order.Field)
+		}
+		if seen[order.Field] {
+			return nil, fmt.Errorf("order by field %q repeated", order.Field)
+		}
+		seen[order.Field] = true
+
+		if len(words) == 2 {
+			switch strings.ToLower(words[1]) {
+			case "asc":
+			case "desc":
+				order.Desc = true
+			default:
+				return nil, fmt.Errorf("invalid order by direction %q", words[1])
+			}
+		}
+
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// applyOptions appends the ordering and range of opts to query.
+func (r *racesRepo) applyOptions(query string, opts ListOptions) (string, []interface{}, error) {
+	var (
+		terms []string
+		args  []interface{}
+		byID  bool
+	)
+
+	for _, order := range opts.OrderBy {
+		column, ok := orderColumns[order.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("unsupported order by field %q", order.Field)
+		}
+
+		if order.Desc {
+			column += " DESC"
+		}
+
+		terms = append(terms, column)
+		byID = byID || order.Field == "id"
+	}
+
+	// Ordering must be total for pages to be stable.
+	if !byID {
+		terms = append(terms, "id")
+	}
+
+	query += " ORDER BY " + strings.Join(terms, ", ")
+
+	if opts.Limit > 0 || opts.Offset > 0 {
+		limit := opts.Limit
+		if limit <= 0 {
+			// SQLite only accepts an offset after a limit, where -1 means none.
+			limit = -1
+		}
+
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, opts.Offset)
+	}
+
+	return query, args, nil
+}
@@ -1,9 +1,24 @@
 package db
 
+import "git.neds.sh/matty/entain/proto/racing"
+
 const (
-	racesList = "list"
+	racesList   = "list"
+	racesGet    = "get"
+	racesStats  = "stats"
+	racesUpsert = "upsert"
 )
 
+// statsColumns maps each stats dimension onto the column expression it is grouped by.
+// Start times are normalised to UTC before bucketing, as they are stored with their offset.
+var statsColumns = map[racing.RaceStatsGroupBy]string{
+	racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_MEETING_ID: "meeting_id",
+	racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_VISIBLE:    "visible",
+	racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_STATUS:     "CASE WHEN datetime(advertised_start_time) <= datetime(?) THEN 'RACE_STATUS_CLOSED' ELSE 'RACE_STATUS_OPEN' END",
+	racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_START_HOUR: "strftime('%Y-%m-%dT%H:00:00Z', advertised_start_time)",
+	racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_START_DAY:  "strftime('%Y-%m-%dT00:00:00Z', advertised_start_time)",
+}
+
 func getRaceQueries() map[string]string {
 	return map[string]string{
 		racesList: `
@@ -13,8 +28,36 @@ func getRaceQueries() map[string]string {
 				name, 
 				number, 
 				visible, 
-				advertised_start_time 
+				advertised_start_time, 
+				race_type 
 			FROM races
 		`,
+		racesGet: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				race_type 
+			FROM races 
+			WHERE id = ?
+		`,
+		racesStats: `
+			SELECT %s 
+			FROM races
+		`,
+		racesUpsert: `
+			INSERT INTO races(id, meeting_id, name, number, visible, advertised_start_time, race_type) 
+			VALUES (?,?,?,?,?,?,?) 
+			ON CONFLICT(id) DO UPDATE SET 
+				meeting_id = excluded.meeting_id, 
+				name = excluded.name, 
+				number = excluded.number, 
+				visible = excluded.visible, 
+				advertised_start_time = excluded.advertised_start_time, 
+				race_type = excluded.race_type
+		`,
 	}
 }
@@ -1,7 +1,9 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,7 +12,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	_ "github.com/mattn/go-sqlite3"
 
-	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/proto/racing"
 )
 
 // RacesRepo provides repository access to races.
@@ -18,35 +20,50 @@ type RacesRepo interface {
 	// Init will initialise our races repository.
 	Init() error
 
-	// List will return a list of races.
-	List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error)
+	// List will return a list of races, ordered and ranged by opts.
+	List(ctx context.Context, filter *racing.ListRacesRequestFilter, opts ListOptions) ([]*racing.Race, error)
+
+	// Get will return the race with the given ID, or nil if there isn't one.
+	Get(ctx context.Context, id int64) (*racing.Race, error)
+
+	// Stats will return race counts grouped by the given dimensions.
+	Stats(ctx context.Context, filter *racing.ListRacesRequestFilter, groupBy []racing.RaceStatsGroupBy) ([]*racing.RaceStatsGroup, error)
+
+	// Upsert will create the race, or replace it if one with the same ID exists.
+	Upsert(ctx context.Context, race *racing.Race) error
 }
 
 type racesRepo struct {
-	db   *sql.DB
-	init sync.Once
+	db            *sql.DB
+	init          sync.Once
+	seedDummyData bool
 }
 
-// NewRacesRepo creates a new races repository.
-func NewRacesRepo(db *sql.DB) RacesRepo {
-	return &racesRepo{db: db}
+// NewRacesRepo creates a new races repository, which seeds the DB with dummy races when seed is set.
+func NewRacesRepo(db *sql.DB, seed bool) RacesRepo {
+	return &racesRepo{db: db, seedDummyData: seed}
 }
 
-// Init prepares the race repository dummy data.
+// Init prepares the race repository schema and, if enabled, dummy data.
 func (r *racesRepo) Init() error {
 	var err error
 
 	r.init.Do(func() {
+		if err = r.createSchema(); err != nil {
+			return
+		}
+
 		// For test/example purposes, we seed the DB with some dummy races.
-		err = r.seed()
+		if r.seedDummyData {
+			err = r.seed()
+		}
 	})
 
 	return err
 }
 
-func (r *racesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+func (r *racesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, opts ListOptions) (races []*racing.Race, err error) {
 	var (
-		err   error
 		query string
 		args  []interface{}
 	)
@@ -55,12 +72,170 @@ func (r *racesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race,
 
 	query, args = r.applyFilter(query, filter)
 
-	rows, err := r.db.Query(query, args...)
+	query, optionArgs, err := r.applyOptions(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, optionArgs...)
+
+	ctx, span := startQuerySpan(ctx, "RacesRepo.List", query)
+	defer func() { endQuerySpan(span, err) }()
+
+	start := time.Now()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	races, err = r.scanRaces(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	observeQuery(racesList, start, len(races))
+
+	return races, nil
+}
+
+func (r *racesRepo) Get(ctx context.Context, id int64) (race *racing.Race, err error) {
+	query := getRaceQueries()[racesGet]
+
+	ctx, span := startQuerySpan(ctx, "RacesRepo.Get", query)
+	defer func() { endQuerySpan(span, err) }()
+
+	start := time.Now()
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	races, err := r.scanRaces(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	observeQuery(racesGet, start, len(races))
+
+	if len(races) == 0 {
+		return nil, nil
+	}
+
+	return races[0], nil
+}
+
+func (r *racesRepo) Upsert(ctx context.Context, race *racing.Race) (err error) {
+	advertisedStart, err := ptypes.Timestamp(race.AdvertisedStartTime)
+	if err != nil {
+		return err
+	}
+
+	query := getRaceQueries()[racesUpsert]
+
+	ctx, span := startQuerySpan(ctx, "RacesRepo.Upsert", query)
+	defer func() { endQuerySpan(span, err) }()
+
+	start := time.Now()
+
+	_, err = r.db.ExecContext(
+		ctx,
+		query,
+		race.Id,
+		race.MeetingId,
+		race.Name,
+		race.Number,
+		race.Visible,
+		advertisedStart.Format(time.RFC3339),
+		race.RaceType,
+	)
+	if err != nil {
+		return err
+	}
+
+	observeQuery(racesUpsert, start, 0)
+
+	return nil
+}
+
+func (r *racesRepo) Stats(ctx context.Context, filter *racing.ListRacesRequestFilter, groupBy []racing.RaceStatsGroupBy) (groups []*racing.RaceStatsGroup, err error) {
+	query, args, err := r.statsQuery(filter, groupBy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := startQuerySpan(ctx, "RacesRepo.Stats", query)
+	defer func() { endQuerySpan(span, err) }()
+
+	start := time.Now()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups, err = r.scanStats(rows, groupBy)
 	if err != nil {
 		return nil, err
 	}
 
-	return r.scanRaces(rows)
+	observeQuery(racesStats, start, len(groups))
+
+	return groups, nil
+}
+
+// statsQuery builds the aggregation query for Stats. Each requested dimension
+// becomes a selected column (in request order) followed by the group count.
+func (r *racesRepo) statsQuery(filter *racing.ListRacesRequestFilter, groupBy []racing.RaceStatsGroupBy, now time.Time) (string, []interface{}, error) {
+	var (
+		columns []string
+		args    []interface{}
+	)
+
+	if err := ValidateGroupBy(groupBy); err != nil {
+		return "", nil, err
+	}
+
+	for _, dimension := range groupBy {
+		column := statsColumns[dimension]
+
+		// Status is derived relative to now, so it needs the current time bound.
+		if dimension == racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_STATUS {
+			args = append(args, now.UTC().Format(time.RFC3339))
+		}
+
+		columns = append(columns, column)
+	}
+
+	query := fmt.Sprintf(getRaceQueries()[racesStats], strings.Join(append(columns, "COUNT(*)"), ", "))
+
+	query, filterArgs := r.applyFilter(query, filter)
+	args = append(args, filterArgs...)
+
+	if len(columns) != 0 {
+		var positions []string
+		for i := range columns {
+			positions = append(positions, strconv.Itoa(i+1))
+		}
+
+		query += " GROUP BY " + strings.Join(positions, ", ") + " ORDER BY " + strings.Join(positions, ", ")
+	}
+
+	return query, args, nil
+}
+
+// ValidateGroupBy checks every dimension in groupBy can be aggregated on.
+func ValidateGroupBy(groupBy []racing.RaceStatsGroupBy) error {
+	for _, dimension := range groupBy {
+		if _, ok := statsColumns[dimension]; !ok {
+			return fmt.Errorf("unsupported group by dimension %s", dimension)
+		}
+	}
+
+	return nil
 }
 
 func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter) (string, []interface{}) {
@@ -83,6 +258,11 @@ func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFil
 	if filter.Visible != nil {
 		clauses = append(clauses, "visible = "+strconv.FormatBool(filter.GetVisible()))
 	}
+	if filter.AdvertisedStartTimeFrom != nil {
+		// Compared as datetimes, as stored times carry differing UTC offsets.
+		clauses = append(clauses, "datetime(advertised_start_time) >= datetime(?)")
+		args = append(args, filter.AdvertisedStartTimeFrom.AsTime().UTC().Format(time.RFC3339))
+	}
 
 	if len(clauses) != 0 {
 		query += " WHERE " + strings.Join(clauses, " AND ")
@@ -100,11 +280,7 @@ func (m *racesRepo) scanRaces(
 		var race racing.Race
 		var advertisedStart time.Time
 
-		if err := rows.Scan(&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, nil
-			}
-
+		if err := rows.Scan(&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart, &race.RaceType); err != nil {
 			return nil, err
 		}
 
@@ -118,5 +294,83 @@ func (m *racesRepo) scanRaces(
 		races = append(races, &race)
 	}
 
-	return races, nil
+	return races, rows.Err()
+}
+
+func (m *racesRepo) scanStats(
+	rows *sql.Rows,
+	groupBy []racing.RaceStatsGroupBy,
+) ([]*racing.RaceStatsGroup, error) {
+	var groups []*racing.RaceStatsGroup
+
+	for rows.Next() {
+		var (
+			group racing.RaceStatsGroup
+			dest  []interface{}
+		)
+
+		// Meeting IDs scan as integers, so a NULL meeting can be told apart
+		// from a malformed one. Every other dimension scans as text.
+		meetingIDs := make([]sql.NullInt64, len(groupBy))
+		values := make([]sql.NullString, len(groupBy))
+		for i, dimension := range groupBy {
+			if dimension == racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_MEETING_ID {
+				dest = append(dest, &meetingIDs[i])
+			} else {
+				dest = append(dest, &values[i])
+			}
+		}
+		dest = append(dest, &group.Count)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		for i, dimension := range groupBy {
+			if dimension == racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_MEETING_ID {
+				if meetingIDs[i].Valid {
+					group.MeetingId = &meetingIDs[i].Int64
+				}
+				continue
+			}
+
+			// Races missing the column are grouped with the field left unset.
+			if !values[i].Valid {
+				continue
+			}
+
+			if err := setStatsDimension(&group, dimension, values[i].String); err != nil {
+				return nil, err
+			}
+		}
+
+		groups = append(groups, &group)
+	}
+
+	return groups, rows.Err()
+}
+
+// setStatsDimension populates the field of group matching dimension from its
+// scanned column value. Meeting IDs are set by scanStats itself.
+func setStatsDimension(group *racing.RaceStatsGroup, dimension racing.RaceStatsGroupBy, value string) error {
+	switch dimension {
+	case racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_VISIBLE:
+		visible := value == "1"
+		group.Visible = &visible
+	case racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_STATUS:
+		group.Status = racing.RaceStatus(racing.RaceStatus_value[value])
+	case racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_START_HOUR, racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_START_DAY:
+		bucket, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+
+		ts, err := ptypes.TimestampProto(bucket)
+		if err != nil {
+			return err
+		}
+		group.StartBucket = ts
+	}
+
+	return nil
 }
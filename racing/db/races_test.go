@@ -1,17 +1,22 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"git.neds.sh/matty/entain/racing/proto/racing"
+	"fmt"
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"reflect"
+	"strconv"
 	"strings"
-	"sync"
 	"testing"
+	"time"
 )
 
 func Test_racesRepo_applyFilter(t *testing.T) {
 	type fields struct {
-		db   *sql.DB
-		init sync.Once
+		db *sql.DB
 	}
 	type args struct {
 		query  string
@@ -31,7 +36,7 @@ func Test_racesRepo_applyFilter(t *testing.T) {
 				getRaceQueries()[racesList],
 				&racing.ListRacesRequestFilter{},
 			},
-			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time FROM races",
+			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time, race_type FROM races",
 		},
 		{
 			name:   "filter single meeting ids",
@@ -42,7 +47,7 @@ func Test_racesRepo_applyFilter(t *testing.T) {
 					MeetingIds: []int64{5},
 				},
 			},
-			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time FROM races WHERE meeting_id IN (?)",
+			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time, race_type FROM races WHERE meeting_id IN (?)",
 		},
 		{
 			name:   "filter multiple meeting ids",
@@ -53,7 +58,7 @@ func Test_racesRepo_applyFilter(t *testing.T) {
 					MeetingIds: []int64{1, 2},
 				},
 			},
-			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time FROM races WHERE meeting_id IN (?,?)",
+			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time, race_type FROM races WHERE meeting_id IN (?,?)",
 		},
 		{
 			name:   "filter with visible is true",
@@ -64,7 +69,7 @@ func Test_racesRepo_applyFilter(t *testing.T) {
 					Visible: boolPtr(true),
 				},
 			},
-			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time FROM races WHERE visible = true",
+			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time, race_type FROM races WHERE visible = true",
 		},
 		{
 			name:   "filter with visible is false",
@@ -75,7 +80,7 @@ func Test_racesRepo_applyFilter(t *testing.T) {
 					Visible: boolPtr(false),
 				},
 			},
-			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time FROM races WHERE visible = false",
+			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time, race_type FROM races WHERE visible = false",
 		},
 		{
 			name:   "filter with visible is false and multiple meeting ids",
@@ -87,7 +92,19 @@ func Test_racesRepo_applyFilter(t *testing.T) {
 					Visible:    boolPtr(false),
 				},
 			},
-			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time FROM races WHERE meeting_id IN (?,?) AND visible = false",
+			want: "SELECT id, meeting_id, name, number, visible, advertised_start_time, race_type FROM races WHERE meeting_id IN (?,?) AND visible = false",
+		},
+		{
+			name:   "filter by advertised start time",
+			fields: fields{},
+			args: args{
+				getRaceQueries()[racesList],
+				&racing.ListRacesRequestFilter{
+					AdvertisedStartTimeFrom: timestamppb.New(time.Date(2021, 3, 1, 20, 0, 0, 0, time.FixedZone("AEST", 10*60*60))),
+				},
+			},
+			want:  "SELECT id, meeting_id, name, number, visible, advertised_start_time, race_type FROM races WHERE datetime(advertised_start_time) >= datetime(?)",
+			want1: []interface{}{"2021-03-01T10:00:00Z"},
 		},
 	}
 	replacer := strings.NewReplacer("\n", "", "\t", "")
@@ -95,18 +112,242 @@ func Test_racesRepo_applyFilter(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &racesRepo{
-				db:   tt.fields.db,
-				init: tt.fields.init,
+				db: tt.fields.db,
 			}
-			got, _ := r.applyFilter(tt.args.query, tt.args.filter)
+			got, got1 := r.applyFilter(tt.args.query, tt.args.filter)
 			if replacer.Replace(got) != tt.want {
 				t.Errorf("applyFilter() got = %v, want %v", got, tt.want)
 			}
+			if tt.want1 != nil && !reflect.DeepEqual(got1, tt.want1) {
+				t.Errorf("applyFilter() got1 = %v, want %v", got1, tt.want1)
+			}
 
 		})
 	}
 }
 
+func Test_racesRepo_statsQuery(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	type args struct {
+		filter  *racing.ListRacesRequestFilter
+		groupBy []racing.RaceStatsGroupBy
+	}
+	tests := []struct {
+		name     string
+		args     args
+		want     string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name: "no grouping",
+			args: args{},
+			want: "SELECT COUNT(*) FROM races",
+		},
+		{
+			name: "group by meeting with filter",
+			args: args{
+				filter: &racing.ListRacesRequestFilter{
+					MeetingIds: []int64{1, 2},
+				},
+				groupBy: []racing.RaceStatsGroupBy{racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_MEETING_ID},
+			},
+			want:     "SELECT meeting_id, COUNT(*) FROM races WHERE meeting_id IN (?,?) GROUP BY 1 ORDER BY 1",
+			wantArgs: []interface{}{int64(1), int64(2)},
+		},
+		{
+			name: "group by status and visible",
+			args: args{
+				filter: &racing.ListRacesRequestFilter{
+					MeetingIds: []int64{3},
+				},
+				groupBy: []racing.RaceStatsGroupBy{
+					racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_STATUS,
+					racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_VISIBLE,
+				},
+			},
+			want:     "SELECT CASE WHEN datetime(advertised_start_time) <= datetime(?) THEN 'RACE_STATUS_CLOSED' ELSE 'RACE_STATUS_OPEN' END, visible, COUNT(*) FROM races WHERE meeting_id IN (?) GROUP BY 1, 2 ORDER BY 1, 2",
+			wantArgs: []interface{}{"2021-03-01T10:00:00Z", int64(3)},
+		},
+		{
+			name: "group by start day",
+			args: args{
+				groupBy: []racing.RaceStatsGroupBy{racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_START_DAY},
+			},
+			want: "SELECT strftime('%Y-%m-%dT00:00:00Z', advertised_start_time), COUNT(*) FROM races GROUP BY 1 ORDER BY 1",
+		},
+		{
+			name: "unspecified dimension",
+			args: args{
+				groupBy: []racing.RaceStatsGroupBy{racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_UNSPECIFIED},
+			},
+			wantErr: true,
+		},
+	}
+	replacer := strings.NewReplacer("\n", "", "\t", "")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &racesRepo{}
+			got, gotArgs, err := r.statsQuery(tt.args.filter, tt.args.groupBy, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("statsQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if replacer.Replace(got) != tt.want {
+				t.Errorf("statsQuery() got = %v, want %v", replacer.Replace(got), tt.want)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("statsQuery() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_racesRepo_Stats(t *testing.T) {
+	db := dbtest.Open(t)
+
+	r := &racesRepo{db: db}
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO races (id, meeting_id, name, number, visible, advertised_start_time) VALUES
+		(1, 5, 'A', 1, 1, '2021-03-01T10:00:00Z'),
+		(2, 5, 'B', 2, 0, '2021-03-01T11:00:00Z'),
+		(3, NULL, 'C', 3, 1, '2021-03-01T12:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := r.Stats(context.Background(), nil, []racing.RaceStatsGroupBy{racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_MEETING_ID})
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+
+	var got []string
+	for _, group := range groups {
+		meeting := "none"
+		if group.MeetingId != nil {
+			meeting = strconv.FormatInt(group.GetMeetingId(), 10)
+		}
+		got = append(got, fmt.Sprintf("%s=%d", meeting, group.Count))
+	}
+	if want := []string{"none=1", "5=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() groups = %v, want %v", got, want)
+	}
+}
+
+func Test_racesRepo_List_advertisedStartTimeFrom(t *testing.T) {
+	db := dbtest.Open(t)
+
+	r := &racesRepo{db: db}
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// Stored with differing offsets, 1 and 3 start before 10:00 UTC.
+	if _, err := db.Exec(`INSERT INTO races (id, meeting_id, name, number, visible, advertised_start_time) VALUES
+		(1, 5, 'A', 1, 1, '2021-03-01T19:59:59+10:00'),
+		(2, 5, 'B', 2, 1, '2021-03-01T20:00:00+10:00'),
+		(3, 5, 'C', 3, 1, '2021-03-01T09:30:00Z'),
+		(4, 5, 'D', 4, 1, '2021-03-01T10:30:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+
+	races, err := r.List(context.Background(), &racing.ListRacesRequestFilter{
+		AdvertisedStartTimeFrom: timestamppb.New(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)),
+	}, ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var got []int64
+	for _, race := range races {
+		got = append(got, race.Id)
+	}
+	if want := []int64{2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() races = %v, want %v", got, want)
+	}
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Order
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "advertised_start_time", want: []Order{{Field: "advertised_start_time"}}},
+		{in: "advertised_start_time DESC, name asc", want: []Order{{Field: "advertised_start_time", Desc: true}, {Field: "name"}}},
+		{in: "status", wantErr: true},
+		{in: "name sideways", wantErr: true},
+		{in: "name, name desc", wantErr: true},
+		{in: "name,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOrderBy(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOrderBy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseOrderBy(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func Test_racesRepo_applyOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     ListOptions
+		want     string
+		wantArgs []interface{}
+	}{
+		{
+			name: "default order",
+			want: "FROM races ORDER BY id",
+		},
+		{
+			name: "order with id tie break",
+			opts: ListOptions{OrderBy: []Order{{Field: "advertised_start_time", Desc: true}}},
+			want: "FROM races ORDER BY datetime(advertised_start_time) DESC, id",
+		},
+		{
+			name: "order by id",
+			opts: ListOptions{OrderBy: []Order{{Field: "id", Desc: true}}},
+			want: "FROM races ORDER BY id DESC",
+		},
+		{
+			name:     "page",
+			opts:     ListOptions{Limit: 10, Offset: 20},
+			want:     "FROM races ORDER BY id LIMIT ? OFFSET ?",
+			wantArgs: []interface{}{10, 20},
+		},
+		{
+			name:     "offset only",
+			opts:     ListOptions{Offset: 20},
+			want:     "FROM races ORDER BY id LIMIT ? OFFSET ?",
+			wantArgs: []interface{}{-1, 20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &racesRepo{}
+
+			got, args, err := r.applyOptions("FROM races", tt.opts)
+			if err != nil {
+				t.Fatalf("applyOptions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("applyOptions() got = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("applyOptions() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
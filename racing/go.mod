@@ -3,13 +3,25 @@ module git.neds.sh/matty/entain/racing
 go 1.16
 
 require (
-	github.com/golang/protobuf v1.4.3
-	github.com/grpc-ecosystem/grpc-gateway/v2 v2.3.0
+	git.neds.sh/matty/entain/pkg v0.0.0
+	git.neds.sh/matty/entain/proto v0.0.0
+	github.com/golang-jwt/jwt/v4 v4.1.0
+	github.com/golang/protobuf v1.5.2
 	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/prometheus/client_golang v1.10.0
+	github.com/sirupsen/logrus v1.8.1
+	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.25.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110
+	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a
 	google.golang.org/genproto v0.0.0-20210226172003-ab064af71705
-	google.golang.org/grpc v1.36.0
-	google.golang.org/grpc/cmd/protoc-gen-go-grpc v1.1.0
-	google.golang.org/protobuf v1.25.1-0.20201208041424-160c7477e0e8
+	google.golang.org/grpc v1.41.0
+	google.golang.org/protobuf v1.27.1
 	syreclabs.com/go/faker v1.2.3
 )
+
+replace (
+	git.neds.sh/matty/entain/pkg => ../pkg
+	git.neds.sh/matty/entain/proto => ../proto
+)
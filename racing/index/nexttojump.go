@@ -0,0 +1,307 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/racing/db"
+)
+
+const (
+	// DefaultNextToJumpLimit is the number of races returned when no limit is requested.
+	DefaultNextToJumpLimit = 5
+	// MaxNextToJumpLimit caps the number of races returned by a single lookup.
+	MaxNextToJumpLimit = 100
+
+	// maxLoadAttempts bounds how many times Load retries a load overtaken by writes.
+	maxLoadAttempts = 3
+)
+
+// errLoadOvertaken is returned by Load when races were written during every
+// attempt, so no snapshot could be swapped in without losing a write.
+var errLoadOvertaken = errors.New("next to jump load overtaken by writes on every attempt")
+
+// NextToJump is an in-memory index of open, visible races ordered by their
+// advertised start time. It is loaded from the races repository, applies
+// races written through TrackWrites as they're written, and drops races as
+// their start time passes. It's also reloaded on an interval, to pick up
+// changes made to the database by anything else.
+type NextToJump struct {
+	// Accessed atomically, so kept first for 64-bit alignment.
+	reloadFailures uint64
+
+	racesRepo db.RacesRepo
+	refresh   time.Duration
+	now       func() time.Time
+
+	mu      sync.RWMutex
+	entries []entry
+	// generation counts the writes applied by Update, so Load can tell when
+	// its snapshot was overtaken by one.
+	generation uint64
+}
+
+// entry pairs a race with its decoded start time, so ordering and expiry
+// don't need to convert timestamps on every lookup.
+type entry struct {
+	start time.Time
+	race  *racing.Race
+}
+
+// NewNextToJump creates a new next to jump index, reloading from racesRepo every refresh.
+// racesRepo should read from the database rather than a cache, so reloads see
+// changes made by anything else as soon as possible.
+func NewNextToJump(racesRepo db.RacesRepo, refresh time.Duration) *NextToJump {
+	return &NextToJump{
+		racesRepo: racesRepo,
+		refresh:   refresh,
+		now:       time.Now,
+	}
+}
+
+// Load replaces the contents of the index with the visible races yet to start.
+// A load overtaken by a write through Update is retried, as its snapshot may
+// predate the write.
+func (n *NextToJump) Load(ctx context.Context) error {
+	for attempt := 0; attempt < maxLoadAttempts; attempt++ {
+		n.mu.RLock()
+		generation := n.generation
+		n.mu.RUnlock()
+
+		entries, err := n.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		n.mu.Lock()
+		swapped := n.generation == generation
+		if swapped {
+			n.entries = entries
+		}
+		n.mu.Unlock()
+
+		if swapped {
+			return nil
+		}
+	}
+
+	return errLoadOvertaken
+}
+
+// load reads the visible races yet to start from the repository, in index order.
+func (n *NextToJump) load(ctx context.Context) ([]entry, error) {
+	now := n.now()
+	visible := true
+
+	races, err := n.racesRepo.List(ctx, &racing.ListRacesRequestFilter{
+		Visible:                 &visible,
+		AdvertisedStartTimeFrom: timestamppb.New(now),
+	}, db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(races))
+
+	for _, race := range races {
+		start, err := ptypes.Timestamp(race.AdvertisedStartTime)
+		if err != nil {
+			return nil, err
+		}
+
+		// The filter includes races starting exactly now, which have jumped.
+		if start.After(now) {
+			entries = append(entries, entry{start: start, race: race})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].less(entries[j])
+	})
+
+	return entries, nil
+}
+
+// Watch keeps the index current until ctx is done. Races are evicted as soon
+// as they jump, and the index is reloaded from the repository every refresh.
+// Reload failures are logged and counted, and keep the previous contents,
+// which are still evicted on time.
+func (n *NextToJump) Watch(ctx context.Context, logger logrus.FieldLogger) {
+	reload := time.NewTicker(n.refresh)
+	defer reload.Stop()
+
+	for {
+		jump := time.NewTimer(n.untilNextJump())
+
+		select {
+		case <-ctx.Done():
+			jump.Stop()
+			return
+		case <-reload.C:
+			if err := n.Load(ctx); err != nil && ctx.Err() == nil {
+				atomic.AddUint64(&n.reloadFailures, 1)
+				logger.WithError(err).Warn("failed reloading next to jump index")
+			}
+		case <-jump.C:
+			n.evict()
+		}
+
+		jump.Stop()
+	}
+}
+
+// ReloadFailures returns how many of Watch's reloads have failed so far.
+func (n *NextToJump) ReloadFailures() uint64 {
+	return atomic.LoadUint64(&n.reloadFailures)
+}
+
+// TrackWrites returns racesRepo with every race it writes applied to the
+// index once the write succeeds.
+func (n *NextToJump) TrackWrites(racesRepo db.RacesRepo) db.RacesRepo {
+	return &trackedRacesRepo{RacesRepo: racesRepo, index: n}
+}
+
+// trackedRacesRepo applies the races written to the wrapped repository to its index.
+type trackedRacesRepo struct {
+	db.RacesRepo
+	index *NextToJump
+}
+
+func (t *trackedRacesRepo) Upsert(ctx context.Context, race *racing.Race) error {
+	if err := t.RacesRepo.Upsert(ctx, race); err != nil {
+		return err
+	}
+
+	return t.index.Update(race)
+}
+
+// Update applies a written race to the index, adding, moving or removing it
+// depending on whether it's visible and yet to start.
+func (n *NextToJump) Update(race *racing.Race) error {
+	start, err := ptypes.Timestamp(race.AdvertisedStartTime)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.generation++
+
+	for i, e := range n.entries {
+		if e.race.Id == race.Id {
+			n.entries = append(n.entries[:i:i], n.entries[i+1:]...)
+			break
+		}
+	}
+
+	if !race.Visible || !start.After(n.now()) {
+		return nil
+	}
+
+	e := entry{start: start, race: race}
+	i := sort.Search(len(n.entries), func(i int) bool {
+		return e.less(n.entries[i])
+	})
+
+	n.entries = append(n.entries[:i:i], append([]entry{e}, n.entries[i:]...)...)
+
+	return nil
+}
+
+// List returns up to limit races, soonest first, optionally restricted to the
+// given meetings and race types.
+func (n *NextToJump) List(limit int, meetingIDs []int64, raceTypes []racing.RaceType) []*racing.Race {
+	if limit <= 0 {
+		limit = DefaultNextToJumpLimit
+	}
+	if limit > MaxNextToJumpLimit {
+		limit = MaxNextToJumpLimit
+	}
+
+	meetings := make(map[int64]bool, len(meetingIDs))
+	for _, meetingID := range meetingIDs {
+		meetings[meetingID] = true
+	}
+
+	types := make(map[racing.RaceType]bool, len(raceTypes))
+	for _, raceType := range raceTypes {
+		types[raceType] = true
+	}
+
+	now := n.now()
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	var races []*racing.Race
+
+	for _, e := range n.entries {
+		if len(races) == limit {
+			break
+		}
+
+		// Eviction runs on a timer, so skip anything that has jumped since.
+		if !e.start.After(now) {
+			continue
+		}
+		if len(meetings) != 0 && !meetings[e.race.MeetingId] {
+			continue
+		}
+		if len(types) != 0 && !types[e.race.RaceType] {
+			continue
+		}
+
+		races = append(races, e.race)
+	}
+
+	return races
+}
+
+// evict removes every race whose advertised start time has passed.
+func (n *NextToJump) evict() {
+	now := n.now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	i := sort.Search(len(n.entries), func(i int) bool {
+		return n.entries[i].start.After(now)
+	})
+	n.entries = n.entries[i:]
+}
+
+// untilNextJump returns how long until the soonest race in the index starts,
+// or the reload interval when the index is empty.
+func (n *NextToJump) untilNextJump() time.Duration {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if len(n.entries) == 0 {
+		return n.refresh
+	}
+
+	wait := n.entries[0].start.Sub(n.now())
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
+
+func (e entry) less(other entry) bool {
+	if e.start.Equal(other.start) {
+		return e.race.Id < other.race.Id
+	}
+
+	return e.start.Before(other.start)
+}
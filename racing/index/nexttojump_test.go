@@ -0,0 +1,320 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/racing/db"
+)
+
+// fakeRacesRepo serves a fixed set of races, honouring only the visible and
+// start time filters. onList, when set, runs once every List has read its
+// races, and err fails it.
+type fakeRacesRepo struct {
+	races  []*racing.Race
+	onList func()
+	err    error
+}
+
+func (f *fakeRacesRepo) Init() error { return nil }
+
+func (f *fakeRacesRepo) List(_ context.Context, filter *racing.ListRacesRequestFilter, _ db.ListOptions) ([]*racing.Race, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var races []*racing.Race
+	for _, race := range f.races {
+		if filter.Visible != nil && race.Visible != filter.GetVisible() {
+			continue
+		}
+		if filter.AdvertisedStartTimeFrom != nil && race.AdvertisedStartTime.AsTime().Before(filter.AdvertisedStartTimeFrom.AsTime()) {
+			continue
+		}
+		races = append(races, race)
+	}
+
+	if f.onList != nil {
+		f.onList()
+	}
+	return races, nil
+}
+
+func (f *fakeRacesRepo) Get(context.Context, int64) (*racing.Race, error) { return nil, nil }
+
+func (f *fakeRacesRepo) Stats(context.Context, *racing.ListRacesRequestFilter, []racing.RaceStatsGroupBy) ([]*racing.RaceStatsGroup, error) {
+	return nil, nil
+}
+
+func (f *fakeRacesRepo) Upsert(context.Context, *racing.Race) error { return nil }
+
+func TestNextToJump_List(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	const (
+		thoroughbred = racing.RaceType_RACE_TYPE_THOROUGHBRED
+		greyhound    = racing.RaceType_RACE_TYPE_GREYHOUND
+	)
+
+	race := func(id, meetingID int64, raceType racing.RaceType, visible bool, start time.Duration) *racing.Race {
+		ts, _ := ptypes.TimestampProto(now.Add(start))
+		return &racing.Race{Id: id, MeetingId: meetingID, RaceType: raceType, Visible: visible, AdvertisedStartTime: ts}
+	}
+
+	repo := &fakeRacesRepo{races: []*racing.Race{
+		race(1, 1, thoroughbred, true, 30*time.Minute),
+		race(2, 1, thoroughbred, true, -time.Minute),
+		race(3, 2, greyhound, true, 5*time.Minute),
+		race(4, 2, greyhound, false, time.Minute),
+		race(5, 3, greyhound, true, 10*time.Minute),
+		race(6, 1, thoroughbred, true, 5*time.Minute),
+	}}
+
+	n := NewNextToJump(repo, time.Minute)
+	n.now = func() time.Time { return now }
+
+	if err := n.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		elapsed    time.Duration
+		limit      int
+		meetingIDs []int64
+		raceTypes  []racing.RaceType
+		want       []int64
+	}{
+		{
+			name: "default limit ordered by start then id",
+			want: []int64{3, 6, 5, 1},
+		},
+		{
+			name:  "limit",
+			limit: 2,
+			want:  []int64{3, 6},
+		},
+		{
+			name:       "meeting filter",
+			meetingIDs: []int64{1, 3},
+			want:       []int64{6, 5, 1},
+		},
+		{
+			name:      "race type filter",
+			raceTypes: []racing.RaceType{greyhound},
+			want:      []int64{3, 5},
+		},
+		{
+			name:       "meeting and race type filters",
+			meetingIDs: []int64{1, 2},
+			raceTypes:  []racing.RaceType{thoroughbred},
+			want:       []int64{6, 1},
+		},
+		{
+			name:    "jumped races are skipped",
+			elapsed: 5 * time.Minute,
+			want:    []int64{5, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n.now = func() time.Time { return now.Add(tt.elapsed) }
+
+			var got []int64
+			for _, race := range n.List(tt.limit, tt.meetingIDs, tt.raceTypes) {
+				got = append(got, race.Id)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("List() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("List() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNextToJump_evict(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	first, _ := ptypes.TimestampProto(now.Add(time.Minute))
+	second, _ := ptypes.TimestampProto(now.Add(time.Hour))
+
+	repo := &fakeRacesRepo{races: []*racing.Race{
+		{Id: 1, Visible: true, AdvertisedStartTime: first},
+		{Id: 2, Visible: true, AdvertisedStartTime: second},
+	}}
+
+	n := NewNextToJump(repo, time.Minute)
+	n.now = func() time.Time { return now }
+
+	if err := n.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := n.untilNextJump(); got != time.Minute {
+		t.Errorf("untilNextJump() = %v, want %v", got, time.Minute)
+	}
+
+	n.now = func() time.Time { return now.Add(time.Minute) }
+	n.evict()
+
+	if len(n.entries) != 1 || n.entries[0].race.Id != 2 {
+		t.Errorf("evict() left %v, want only race 2", n.entries)
+	}
+}
+
+func TestNextToJump_TrackWrites(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	race := func(id int64, visible bool, start time.Duration) *racing.Race {
+		ts, _ := ptypes.TimestampProto(now.Add(start))
+		return &racing.Race{Id: id, Visible: visible, AdvertisedStartTime: ts}
+	}
+
+	n := NewNextToJump(&fakeRacesRepo{races: []*racing.Race{
+		race(1, true, time.Minute),
+		race(2, true, time.Hour),
+	}}, time.Minute)
+	n.now = func() time.Time { return now }
+
+	if err := n.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	repo := n.TrackWrites(&fakeRacesRepo{})
+
+	tests := []struct {
+		name  string
+		write *racing.Race
+		want  []int64
+	}{
+		{name: "new race", write: race(3, true, 30*time.Minute), want: []int64{1, 3, 2}},
+		{name: "moved race", write: race(1, true, 2*time.Hour), want: []int64{3, 2, 1}},
+		{name: "hidden race", write: race(3, false, 30*time.Minute), want: []int64{2, 1}},
+		{name: "jumped race", write: race(2, true, -time.Minute), want: []int64{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.Upsert(context.Background(), tt.write); err != nil {
+				t.Fatalf("Upsert() error = %v", err)
+			}
+
+			var got []int64
+			for _, race := range n.List(0, nil, nil) {
+				got = append(got, race.Id)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("List() after Upsert() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("List() after Upsert() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNextToJump_Load_overtaken(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	race := func(id int64, start time.Duration) *racing.Race {
+		ts, _ := ptypes.TimestampProto(now.Add(start))
+		return &racing.Race{Id: id, Visible: true, AdvertisedStartTime: ts}
+	}
+
+	tests := []struct {
+		name      string
+		overtaken int
+		wantErr   error
+		want      []int64
+	}{
+		{name: "not overtaken", want: []int64{1}},
+		{name: "retried", overtaken: 1, want: []int64{2, 1}},
+		{name: "overtaken on every attempt", overtaken: maxLoadAttempts, wantErr: errLoadOvertaken, want: []int64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRacesRepo{races: []*racing.Race{race(1, time.Hour)}}
+			n := NewNextToJump(repo, time.Minute)
+			n.now = func() time.Time { return now }
+
+			// Writes race 2 just after each of the first overtaken
+			// snapshots is read, so they all miss it.
+			writes := 0
+			repo.onList = func() {
+				if writes == tt.overtaken {
+					return
+				}
+				if writes == 0 {
+					repo.races = append(repo.races, race(2, time.Minute))
+				}
+				writes++
+
+				if err := n.Update(race(2, time.Minute)); err != nil {
+					t.Error(err)
+				}
+			}
+
+			if err := n.Load(context.Background()); err != tt.wantErr {
+				t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var got []int64
+			for _, race := range n.List(0, nil, nil) {
+				got = append(got, race.Id)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("List() after Load() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("List() after Load() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNextToJump_Watch_reloadFailure(t *testing.T) {
+	n := NewNextToJump(&fakeRacesRepo{err: errors.New("database is locked")}, time.Millisecond)
+
+	logger, hook := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.Watch(ctx, logger)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for n.ReloadFailures() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("ReloadFailures() = 0 after failing reloads")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.WarnLevel || entry.Data[logrus.ErrorKey] == nil {
+		t.Errorf("last log entry = %v, want a warning with the error", entry)
+	}
+}
@@ -1,59 +1,302 @@
 package main
 
 import (
+	"context"
+	"crypto/rsa"
 	"database/sql"
 	"flag"
-	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"git.neds.sh/matty/entain/pkg/grpcserver/deadline"
+	"git.neds.sh/matty/entain/pkg/grpcserver/health"
+	"git.neds.sh/matty/entain/pkg/grpcserver/logging"
+	"git.neds.sh/matty/entain/pkg/grpcserver/metrics"
+	"git.neds.sh/matty/entain/pkg/grpcserver/recovery"
+	"git.neds.sh/matty/entain/pkg/grpcserver/validation"
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+	"git.neds.sh/matty/entain/pkg/settings"
+	"git.neds.sh/matty/entain/pkg/tlsutil"
+	"git.neds.sh/matty/entain/pkg/tracing"
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/racing/auth"
+	"git.neds.sh/matty/entain/racing/config"
 	"git.neds.sh/matty/entain/racing/db"
-	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/index"
 	"git.neds.sh/matty/entain/racing/service"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-)
-
-var (
-	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
-	flag.Parse()
+	cfg := config.Default()
+	printConfig, err := settings.LoadConfig(os.Args, config.EnvPrefix, cfg)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		logrus.Fatalf("failed loading config: %s", err)
+	}
+
+	if printConfig {
+		if err := settings.Print(os.Stdout, cfg); err != nil {
+			logrus.Fatalf("failed printing config: %s", err)
+		}
+		return
+	}
+
+	logger, err := logging.NewLogger(cfg.LogLevel)
+	if err != nil {
+		logrus.Fatalf("invalid log level: %s", err)
+	}
 
-	if err := run(); err != nil {
-		log.Fatalf("failed running grpc server: %s\n", err)
+	if err := run(cfg, logger); err != nil {
+		logger.WithError(err).Fatal("failed running grpc server")
 	}
 }
 
-func run() error {
-	conn, err := net.Listen("tcp", ":9000")
+// metricsShutdownTimeout bounds closing the metrics server, once the main
+// server has drained.
+const metricsShutdownTimeout = 5 * time.Second
+
+func run(cfg *config.Config, logger *logrus.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, "racing", cfg.TraceExporter, cfg.OTLPEndpoint)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.WithError(err).Warn("failed flushing traces")
+		}
+	}()
 
-	racingDB, err := sql.Open("sqlite3", "./db/racing.db")
+	conn, err := net.Listen("tcp", cfg.GRPCEndpoint)
 	if err != nil {
 		return err
 	}
 
-	racesRepo := db.NewRacesRepo(racingDB)
-	if err := racesRepo.Init(); err != nil {
+	racingDB, err := sql.Open("sqlite3", cfg.DBPath)
+	if err != nil {
 		return err
 	}
+	defer racingDB.Close()
+
+	metrics.RegisterDBStats("racing", racingDB)
 
-	grpcServer := grpc.NewServer()
+	racesRepo := db.NewRacesRepo(racingDB, cfg.DBSeed)
+
+	// Reloaded straight from the database, as the cache could hand back
+	// listings up to its TTL old.
+	nextToJump := index.NewNextToJump(racesRepo, cfg.NextToJumpRefresh)
+	metrics.RegisterCounterFunc("racing_next_to_jump_reload_failures_total", "Next to jump index reloads that failed.", func() float64 {
+		return float64(nextToJump.ReloadFailures())
+	})
+
+	if cfg.CacheEnabled {
+		cachedRacesRepo := db.NewCachedRacesRepo(racesRepo, cfg.CacheSize, cfg.CacheTTL)
+		metrics.RegisterCounterFunc("racing_races_cache_hits_total", "Race listings served from the cache.", func() float64 {
+			return float64(cachedRacesRepo.CacheStats().Hits)
+		})
+		metrics.RegisterCounterFunc("racing_races_cache_misses_total", "Race listings loaded from the database.", func() float64 {
+			return float64(cachedRacesRepo.CacheStats().Misses)
+		})
+
+		racesRepo = cachedRacesRepo
+	}
+
+	racesRepo = nextToJump.TrackWrites(racesRepo)
+
+	healthChecker := health.NewChecker(racingDB, cfg.HealthInterval, racing.Racing_ServiceDesc.ServiceName)
+
+	serverOpts, err := tlsutil.GRPCServerOptions(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return err
+	}
+
+	authorizer, err := newAuthorizer(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.AuthHMACSecret == "" && cfg.AuthJWKSFile == "" {
+		logger.Warn("authentication disabled, every caller is trusted")
+	}
+
+	// Already validated with the rest of the config.
+	methodTimeouts, _ := deadline.ParseMethodTimeouts(cfg.RPCMethodTimeouts)
+	deadlines := deadline.Options{
+		Timeouts: deadline.Timeouts{Default: cfg.RPCDefaultTimeout, Max: cfg.RPCMaxTimeout},
+		Methods:  methodTimeouts,
+	}
+
+	// Inside logging and metrics, so recovered panics are logged and counted
+	// as Internal errors.
+	onPanic := []recovery.Handler{logging.LogPanic, func(_ context.Context, method string, _ interface{}, _ []byte) {
+		metrics.RecordPanic(method)
+	}}
+
+	grpcServer := grpc.NewServer(append(serverOpts,
+		// Let gateways ping idle connections to keep them alive, as long as
+		// they don't ping more often than this.
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			logging.UnaryServerInterceptor(logger),
+			metrics.UnaryServerInterceptor(),
+			recovery.UnaryServerInterceptor(onPanic...),
+			deadline.UnaryServerInterceptor(deadlines),
+			authorizer.UnaryServerInterceptor(),
+			validation.UnaryServerInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(),
+			logging.StreamServerInterceptor(logger),
+			metrics.StreamServerInterceptor(),
+			recovery.StreamServerInterceptor(onPanic...),
+			deadline.StreamServerInterceptor(deadlines),
+			authorizer.StreamServerInterceptor(),
+			validation.StreamServerInterceptor(),
+		),
+	)...)
 
 	racing.RegisterRacingServer(
 		grpcServer,
 		service.NewRacingService(
 			racesRepo,
+			nextToJump,
 		),
 	)
+	healthpb.RegisterHealthServer(grpcServer, healthChecker.Server())
 
-	log.Printf("gRPC server listening on: %s\n", *grpcEndpoint)
+	if cfg.GRPCReflection {
+		reflection.Register(grpcServer)
+	}
 
-	if err := grpcServer.Serve(conn); err != nil {
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+
+	metricsServer := &http.Server{Addr: cfg.MetricsEndpoint, Handler: metricsMux}
+
+	go func() {
+		logger.Infof("metrics server listening on: %s", cfg.MetricsEndpoint)
+
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Error("failed running metrics server")
+		}
+	}()
+
+	logger.WithFields(logrus.Fields{
+		"tls":        cfg.TLSCertFile != "",
+		"mutual_tls": cfg.TLSClientCAFile != "",
+	}).Infof("gRPC server listening on: %s", cfg.GRPCEndpoint)
+
+	// The repository and index are ready before serving, so no RPC can reach
+	// a half initialised database or an empty next to jump index.
+	if err := racesRepo.Init(); err != nil {
+		return err
+	}
+
+	if err := nextToJump.Load(ctx); err != nil {
 		return err
 	}
 
+	go nextToJump.Watch(ctx, logger)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(conn)
+	}()
+
+	healthChecker.SetReady(ctx)
+	go healthChecker.Watch(ctx)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Restore default signal handling, so a second signal kills the process
+	// rather than waiting out the drain.
+	stop()
+
+	logger.Info("shutting down, draining in-flight requests")
+
+	// Report NOT_SERVING first, so traffic is routed away before draining.
+	healthChecker.Shutdown()
+	time.Sleep(cfg.ShutdownDelay)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-drainCtx.Done():
+		logger.Warn("drain timeout exceeded, closing remaining connections")
+		grpcServer.Stop()
+	}
+
+	// The drain may have used up drainCtx, so the metrics server gets its own.
+	metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancelMetrics()
+
+	if err := metricsServer.Shutdown(metricsCtx); err != nil {
+		logger.WithError(err).Warn("failed shutting down metrics server")
+	}
+
 	return nil
 }
+
+// rpcPolicy opens the read only RPCs up to everyone. Anything else, such as
+// RPCs that change races, requires the trader role.
+var rpcPolicy = auth.Policy{
+	"/racing.Racing/ListRaces":      auth.RolePublic,
+	"/racing.Racing/GetRace":        auth.RolePublic,
+	"/racing.Racing/GetRaceStats":   auth.RolePublic,
+	"/racing.Racing/ListNextToJump": auth.RolePublic,
+	"/grpc.health.v1.Health/Check":  auth.RolePublic,
+	"/grpc.health.v1.Health/Watch":  auth.RolePublic,
+
+	// Reflection only describes the protos, and is off unless enabled.
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": auth.RolePublic,
+}
+
+// newAuthorizer returns the authorizer enforcing rpcPolicy, verifying tokens
+// with the configured secret and keys, or trusting everyone when neither is set.
+func newAuthorizer(cfg *config.Config) (*auth.Authorizer, error) {
+	if cfg.AuthHMACSecret == "" && cfg.AuthJWKSFile == "" {
+		return auth.NewAuthorizer(nil, rpcPolicy), nil
+	}
+
+	var (
+		keys map[string]*rsa.PublicKey
+		err  error
+	)
+
+	if cfg.AuthJWKSFile != "" {
+		if keys, err = jwtauth.LoadJWKS(cfg.AuthJWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return auth.NewAuthorizer(jwtauth.NewVerifier([]byte(cfg.AuthHMACSecret), keys), rpcPolicy), nil
+}
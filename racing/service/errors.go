@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// raceResourceType identifies races in ResourceInfo error details.
+const raceResourceType = "racing.Race"
+
+// invalidArgument returns an InvalidArgument error for a request field, with a
+// BadRequest detail so clients can tell which field to fix.
+func invalidArgument(field, description string) error {
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("%s: %s", field, description))
+
+	withDetails, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}},
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// raceNotFound returns a NotFound error for a race, with a ResourceInfo detail
+// naming it.
+func raceNotFound(id int64) error {
+	st := status.Newf(codes.NotFound, "race %d not found", id)
+
+	withDetails, err := st.WithDetails(&errdetails.ResourceInfo{
+		ResourceType: raceResourceType,
+		ResourceName: fmt.Sprint(id),
+		Description:  "race not found",
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
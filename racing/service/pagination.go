@@ -0,0 +1,62 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/proto/racing"
+)
+
+// pageToken encodes the position of the next page. It carries a fingerprint of
+// the query it belongs to, so it can't be replayed against a different filter
+// or order, which would silently skip or repeat races.
+func pageToken(offset int, in *racing.ListRacesRequest) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", offset, queryFingerprint(in))))
+}
+
+// parsePageToken returns the offset encoded in the request's page token, or 0 for the first page.
+func parsePageToken(in *racing.ListRacesRequest) (int, error) {
+	if in.PageToken == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(in.PageToken)
+	if err != nil {
+		return 0, fmt.Errorf("malformed page token")
+	}
+
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("malformed page token")
+	}
+
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("malformed page token")
+	}
+
+	if parts[1] != strconv.FormatUint(queryFingerprint(in), 10) {
+		return 0, fmt.Errorf("page token does not match the filter and order of the request")
+	}
+
+	return offset, nil
+}
+
+// queryFingerprint hashes the parts of a request which determine the races
+// and their order, ignoring the page size and token.
+func queryFingerprint(in *racing.ListRacesRequest) uint64 {
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(&racing.ListRacesRequest{
+		Filter:  in.Filter,
+		OrderBy: in.OrderBy,
+	})
+
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+
+	return h.Sum64()
+}
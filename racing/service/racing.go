@@ -1,31 +1,164 @@
 package service
 
 import (
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/racing/auth"
 	"git.neds.sh/matty/entain/racing/db"
-	"git.neds.sh/matty/entain/racing/proto/racing"
+	"git.neds.sh/matty/entain/racing/index"
 	"golang.org/x/net/context"
+	"google.golang.org/protobuf/proto"
 )
 
 type Racing interface {
 	// ListRaces will return a collection of races.
 	ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error)
+
+	// GetRace will return a single race by its ID.
+	GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.Race, error)
+
+	// GetRaceStats will return race counts grouped by the requested dimensions.
+	GetRaceStats(ctx context.Context, in *racing.GetRaceStatsRequest) (*racing.GetRaceStatsResponse, error)
+
+	// ListNextToJump will return the soonest open and visible races.
+	ListNextToJump(ctx context.Context, in *racing.ListNextToJumpRequest) (*racing.ListNextToJumpResponse, error)
+
+	// PutRace will create or replace a race.
+	PutRace(ctx context.Context, in *racing.PutRaceRequest) (*racing.Race, error)
 }
 
 // racingService implements the Racing interface.
 type racingService struct {
-	racesRepo db.RacesRepo
+	racesRepo  db.RacesRepo
+	nextToJump *index.NextToJump
 }
 
 // NewRacingService instantiates and returns a new racingService.
-func NewRacingService(racesRepo db.RacesRepo) Racing {
-	return &racingService{racesRepo}
+func NewRacingService(racesRepo db.RacesRepo, nextToJump *index.NextToJump) Racing {
+	return &racingService{racesRepo, nextToJump}
 }
 
 func (s *racingService) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
-	races, err := s.racesRepo.List(in.Filter)
+	orderBy, err := db.ParseOrderBy(in.OrderBy)
+	if err != nil {
+		return nil, invalidArgument("order_by", err.Error())
+	}
+
+	if in.PageSize < 0 {
+		return nil, invalidArgument("page_size", "must not be negative")
+	}
+	// Its upper bound is checked against the field's rules by the validation
+	// interceptor.
+	pageSize := int(in.PageSize)
+
+	offset, err := parsePageToken(in)
+	if err != nil {
+		return nil, invalidArgument("page_token", err.Error())
+	}
+
+	filter, ok := visibleFilter(ctx, in.Filter)
+	if !ok {
+		return &racing.ListRacesResponse{}, nil
+	}
+
+	opts := db.ListOptions{OrderBy: orderBy, Offset: offset}
+	if pageSize > 0 {
+		// Fetch one extra race to find out whether there's another page.
+		opts.Limit = pageSize + 1
+	}
+
+	races, err := s.racesRepo.List(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &racing.ListRacesResponse{Races: races}
+	if pageSize > 0 && len(races) > pageSize {
+		resp.Races = races[:pageSize]
+		resp.NextPageToken = pageToken(offset+pageSize, in)
+	}
+
+	return resp, nil
+}
+
+func (s *racingService) GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.Race, error) {
+	race, err := s.racesRepo.Get(ctx, in.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hidden races are reported as missing, rather than forbidden, so callers
+	// can't tell which IDs belong to them.
+	if race == nil || (!race.Visible && !auth.HasRole(ctx, auth.RoleInternal)) {
+		return nil, raceNotFound(in.Id)
+	}
+
+	return race, nil
+}
+
+func (s *racingService) GetRaceStats(ctx context.Context, in *racing.GetRaceStatsRequest) (*racing.GetRaceStatsResponse, error) {
+	if err := db.ValidateGroupBy(in.GroupBy); err != nil {
+		return nil, invalidArgument("group_by", err.Error())
+	}
+
+	filter, ok := visibleFilter(ctx, in.Filter)
+	if !ok {
+		return &racing.GetRaceStatsResponse{}, nil
+	}
+
+	groups, err := s.racesRepo.Stats(ctx, filter, in.GroupBy)
 	if err != nil {
 		return nil, err
 	}
 
-	return &racing.ListRacesResponse{Races: races}, nil
+	var total int64
+	for _, group := range groups {
+		total += group.Count
+	}
+
+	return &racing.GetRaceStatsResponse{Groups: groups, Total: total}, nil
+}
+
+func (s *racingService) ListNextToJump(ctx context.Context, in *racing.ListNextToJumpRequest) (*racing.ListNextToJumpResponse, error) {
+	races := s.nextToJump.List(int(in.Limit), in.MeetingIds, in.RaceTypes)
+
+	return &racing.ListNextToJumpResponse{Races: races}, nil
+}
+
+func (s *racingService) PutRace(ctx context.Context, in *racing.PutRaceRequest) (*racing.Race, error) {
+	if in.Race == nil {
+		return nil, invalidArgument("race", "must be set")
+	}
+	if in.Race.AdvertisedStartTime == nil {
+		return nil, invalidArgument("race.advertised_start_time", "must be set")
+	}
+
+	// Writing through the repository invalidates the cache and updates the
+	// next to jump index.
+	if err := s.racesRepo.Upsert(ctx, in.Race); err != nil {
+		return nil, err
+	}
+
+	return in.Race, nil
+}
+
+// visibleFilter restricts filter to visible races unless the caller is
+// internal, reporting false when the filter can only match hidden races.
+// The request's filter is left untouched.
+func visibleFilter(ctx context.Context, filter *racing.ListRacesRequestFilter) (*racing.ListRacesRequestFilter, bool) {
+	if auth.HasRole(ctx, auth.RoleInternal) {
+		return filter, true
+	}
+
+	if filter == nil {
+		filter = &racing.ListRacesRequestFilter{}
+	} else if filter.Visible != nil && !filter.GetVisible() {
+		return nil, false
+	} else {
+		filter = proto.Clone(filter).(*racing.ListRacesRequestFilter)
+	}
+
+	visible := true
+	filter.Visible = &visible
+
+	return filter, true
 }
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/pkg/jwtauth"
+	"git.neds.sh/matty/entain/proto/racing"
+	"git.neds.sh/matty/entain/racing/auth"
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/index"
+)
+
+func Test_visibleFilter(t *testing.T) {
+	visible, hidden := true, false
+
+	tests := []struct {
+		name        string
+		internal    bool
+		filter      *racing.ListRacesRequestFilter
+		wantVisible *bool
+		wantOK      bool
+	}{
+		{name: "public, no filter", filter: nil, wantVisible: &visible, wantOK: true},
+		{name: "public, visible", filter: &racing.ListRacesRequestFilter{Visible: &visible}, wantVisible: &visible, wantOK: true},
+		{name: "public, hidden", filter: &racing.ListRacesRequestFilter{Visible: &hidden}, wantOK: false},
+		{name: "internal, no filter", internal: true, filter: &racing.ListRacesRequestFilter{}, wantVisible: nil, wantOK: true},
+		{name: "internal, hidden", internal: true, filter: &racing.ListRacesRequestFilter{Visible: &hidden}, wantVisible: &hidden, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := callerContext(t, tt.internal)
+
+			var original *racing.ListRacesRequestFilter
+			if tt.filter != nil {
+				original = &racing.ListRacesRequestFilter{Visible: tt.filter.Visible}
+			}
+
+			got, ok := visibleFilter(ctx, tt.filter)
+			if ok != tt.wantOK {
+				t.Fatalf("visibleFilter() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+
+			if (got.Visible == nil) != (tt.wantVisible == nil) || (got.Visible != nil && *got.Visible != *tt.wantVisible) {
+				t.Errorf("visibleFilter() visible = %v, want %v", got.Visible, tt.wantVisible)
+			}
+			if tt.filter != nil && tt.filter.Visible != original.Visible {
+				t.Errorf("visibleFilter() modified the request filter")
+			}
+		})
+	}
+}
+
+// callerContext returns the context the auth interceptor gives an anonymous
+// caller, or an internal one when authentication is disabled.
+func callerContext(t *testing.T, internal bool) context.Context {
+	t.Helper()
+
+	verifier := jwtauth.NewVerifier([]byte("secret"), nil)
+	if internal {
+		verifier = nil
+	}
+
+	var ctx context.Context
+	_, err := auth.NewAuthorizer(verifier, auth.Policy{"/test": auth.RolePublic}).UnaryServerInterceptor()(
+		context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test"},
+		func(c context.Context, _ interface{}) (interface{}, error) {
+			ctx = c
+			return nil, nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
+
+func Test_racingService_ListRaces_paging(t *testing.T) {
+	repo := &pagingRacesRepo{}
+	for id := int64(1); id <= 5; id++ {
+		repo.races = append(repo.races, &racing.Race{Id: id, Visible: true})
+	}
+
+	svc := NewRacingService(repo, nil)
+	ctx := callerContext(t, false)
+
+	var (
+		got   []int64
+		token string
+		pages int
+	)
+
+	for {
+		resp, err := svc.ListRaces(ctx, &racing.ListRacesRequest{OrderBy: "id", PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatalf("ListRaces() error = %v", err)
+		}
+
+		for _, race := range resp.Races {
+			got = append(got, race.Id)
+		}
+		pages++
+
+		if token = resp.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	if pages != 3 || len(got) != 5 || got[0] != 1 || got[4] != 5 {
+		t.Errorf("ListRaces() paged %v over %d pages, want races 1-5 over 3 pages", got, pages)
+	}
+
+	// Tokens are bound to the filter and order they were issued for.
+	first, err := svc.ListRaces(ctx, &racing.ListRacesRequest{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.ListRaces(ctx, &racing.ListRacesRequest{OrderBy: "name", PageSize: 2, PageToken: first.NextPageToken})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListRaces() with mismatched token error = %v, want InvalidArgument", err)
+	}
+
+	for field, in := range map[string]*racing.ListRacesRequest{
+		"order_by":   {OrderBy: "status"},
+		"page_size":  {PageSize: -1},
+		"page_token": {PageToken: "!"},
+	} {
+		_, err := svc.ListRaces(ctx, in)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListRaces(%v) error = %v, want InvalidArgument", in, err)
+			continue
+		}
+
+		if got := violatedFields(err); len(got) != 1 || got[0] != field {
+			t.Errorf("ListRaces(%v) field violations = %v, want %s", in, got, field)
+		}
+	}
+}
+
+// pagingRacesRepo serves races in id order, honouring only the limit and offset.
+// Get finds a race by id.
+type pagingRacesRepo struct {
+	db.RacesRepo
+	races []*racing.Race
+}
+
+func (p *pagingRacesRepo) List(_ context.Context, _ *racing.ListRacesRequestFilter, opts db.ListOptions) ([]*racing.Race, error) {
+	races := p.races
+	if opts.Offset >= len(races) {
+		return nil, nil
+	}
+	races = races[opts.Offset:]
+
+	if opts.Limit > 0 && opts.Limit < len(races) {
+		races = races[:opts.Limit]
+	}
+
+	return races, nil
+}
+
+func (p *pagingRacesRepo) Get(_ context.Context, id int64) (*racing.Race, error) {
+	for _, race := range p.races {
+		if race.Id == id {
+			return race, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func Test_racingService_GetRace(t *testing.T) {
+	repo := &pagingRacesRepo{races: []*racing.Race{
+		{Id: 1, Visible: true},
+		{Id: 2, Visible: false},
+	}}
+	svc := NewRacingService(repo, nil)
+
+	tests := []struct {
+		name     string
+		internal bool
+		id       int64
+		wantCode codes.Code
+	}{
+		{name: "visible", id: 1, wantCode: codes.OK},
+		{name: "hidden", id: 2, wantCode: codes.NotFound},
+		{name: "hidden, internal", internal: true, id: 2, wantCode: codes.OK},
+		{name: "missing", id: 3, wantCode: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			race, err := svc.GetRace(callerContext(t, tt.internal), &racing.GetRaceRequest{Id: tt.id})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("GetRace() error = %v, want %v", err, tt.wantCode)
+			}
+			if err == nil && race.Id != tt.id {
+				t.Errorf("GetRace() = race %d, want %d", race.Id, tt.id)
+			}
+
+			if tt.wantCode == codes.NotFound {
+				details := status.Convert(err).Details()
+				if len(details) != 1 {
+					t.Fatalf("GetRace() error details = %v, want ResourceInfo", details)
+				}
+				if info, ok := details[0].(*errdetails.ResourceInfo); !ok || info.ResourceName != fmt.Sprint(tt.id) {
+					t.Errorf("GetRace() error detail = %v, want ResourceInfo for race %d", details[0], tt.id)
+				}
+			}
+		})
+	}
+}
+
+func Test_racingService_GetRaceStats_unsupportedGroupBy(t *testing.T) {
+	svc := NewRacingService(&pagingRacesRepo{}, nil)
+
+	_, err := svc.GetRaceStats(callerContext(t, false), &racing.GetRaceStatsRequest{
+		GroupBy: []racing.RaceStatsGroupBy{racing.RaceStatsGroupBy_RACE_STATS_GROUP_BY_UNSPECIFIED},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("GetRaceStats() error = %v, want InvalidArgument", err)
+	}
+	if fields := violatedFields(err); len(fields) != 1 || fields[0] != "group_by" {
+		t.Errorf("GetRaceStats() violated fields = %v, want [group_by]", fields)
+	}
+}
+
+func Test_racingService_PutRace(t *testing.T) {
+	sqlDB := dbtest.Open(t)
+
+	// Wire the repository as main does, so writes go through the cache and
+	// the next to jump index.
+	var racesRepo db.RacesRepo = db.NewCachedRacesRepo(db.NewRacesRepo(sqlDB, false), 10, time.Hour)
+	if err := racesRepo.Init(); err != nil {
+		t.Fatal(err)
+	}
+	nextToJump := index.NewNextToJump(racesRepo, time.Hour)
+	racesRepo = nextToJump.TrackWrites(racesRepo)
+
+	svc := NewRacingService(racesRepo, nextToJump)
+	ctx := callerContext(t, false)
+
+	listIDs := func() (races, next []int64) {
+		t.Helper()
+
+		listed, err := svc.ListRaces(ctx, &racing.ListRacesRequest{})
+		if err != nil {
+			t.Fatalf("ListRaces() error = %v", err)
+		}
+		for _, race := range listed.Races {
+			races = append(races, race.Id)
+		}
+
+		jumping, err := svc.ListNextToJump(ctx, &racing.ListNextToJumpRequest{})
+		if err != nil {
+			t.Fatalf("ListNextToJump() error = %v", err)
+		}
+		for _, race := range jumping.Races {
+			next = append(next, race.Id)
+		}
+
+		return races, next
+	}
+
+	// Cache the empty listing before writing.
+	if races, next := listIDs(); len(races) != 0 || len(next) != 0 {
+		t.Fatalf("listed races %v and next to jump %v before any were put", races, next)
+	}
+
+	race := &racing.Race{Id: 7, MeetingId: 1, Name: "Race", Visible: true, AdvertisedStartTime: timestamppb.New(time.Now().Add(time.Hour))}
+	if _, err := svc.PutRace(ctx, &racing.PutRaceRequest{Race: race}); err != nil {
+		t.Fatalf("PutRace() error = %v", err)
+	}
+
+	if races, next := listIDs(); !reflect.DeepEqual(races, []int64{7}) || !reflect.DeepEqual(next, []int64{7}) {
+		t.Errorf("after PutRace() listed races %v and next to jump %v, want [7] for both", races, next)
+	}
+
+	if _, err := svc.PutRace(ctx, &racing.PutRaceRequest{Race: &racing.Race{Id: 8}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PutRace() without a start time error = %v, want InvalidArgument", err)
+	}
+}
+
+// violatedFields returns the fields named by err's BadRequest details.
+func violatedFields(err error) []string {
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+
+	return fields
+}
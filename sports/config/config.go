@@ -0,0 +1,78 @@
+package config
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/settings"
+)
+
+// EnvPrefix prefixes the environment variable of every setting, e.g. SPORTS_GRPC_ENDPOINT.
+const EnvPrefix = "SPORTS"
+
+// Config holds the sports service's configuration.
+type Config struct {
+	GRPCEndpoint    string
+	MetricsEndpoint string
+
+	DBPath string
+	DBSeed bool
+
+	settings.ServerTLS
+	settings.RPCTimeouts
+
+	HealthInterval  time.Duration
+	ShutdownTimeout time.Duration
+
+	settings.Telemetry
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		GRPCEndpoint:    "localhost:10000",
+		MetricsEndpoint: "localhost:10100",
+		DBPath:          "./db/sports.db",
+		DBSeed:          true,
+		RPCTimeouts:     settings.DefaultRPCTimeouts(),
+		HealthInterval:  5 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+		Telemetry:       settings.DefaultTelemetry(),
+	}
+}
+
+// Settings returns the settings of c, each reading and writing its field.
+func (c *Config) Settings() []settings.Setting {
+	s := []settings.Setting{
+		{Name: "grpc-endpoint", Usage: "gRPC server endpoint", Value: settings.String(&c.GRPCEndpoint)},
+		{Name: "metrics-endpoint", Usage: "Prometheus metrics endpoint", Value: settings.String(&c.MetricsEndpoint)},
+		{Name: "db-path", Usage: "Path to the SQLite events database", Value: settings.String(&c.DBPath)},
+		{Name: "db-seed", Usage: "Seed the database with dummy events on start up", Value: settings.Bool(&c.DBSeed)},
+		{Name: "health-interval", Usage: "Interval between database health checks", Value: settings.Duration(&c.HealthInterval)},
+		{Name: "shutdown-timeout", Usage: "Time allowed for in-flight requests to drain on shutdown", Value: settings.Duration(&c.ShutdownTimeout)},
+	}
+	s = append(s, c.ServerTLS.Settings()...)
+	s = append(s, c.RPCTimeouts.Settings()...)
+
+	return append(s, c.Telemetry.Settings()...)
+}
+
+// Validate checks the configuration is usable, reporting every problem found.
+func (c *Config) Validate() error {
+	var p settings.Problems
+
+	p.Endpoint("grpc-endpoint", c.GRPCEndpoint)
+	p.Endpoint("metrics-endpoint", c.MetricsEndpoint)
+
+	if c.DBPath == "" {
+		p.Addf("db-path", "must be set")
+	}
+
+	p.Positive("health-interval", c.HealthInterval)
+	p.Positive("shutdown-timeout", c.ShutdownTimeout)
+
+	c.ServerTLS.Check(&p)
+	c.RPCTimeouts.Check(&p)
+	c.Telemetry.Check(&p)
+
+	return p.Err()
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/settings"
+)
+
+// load builds a Config from args the way main does.
+func load(args []string) (*Config, bool, error) {
+	cfg := Default()
+	printConfig, err := settings.LoadConfig(args, EnvPrefix, cfg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return cfg, printConfig, nil
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sports.yaml")
+	if err := ioutil.WriteFile(path, []byte("db-path: /data/file.db\nshutdown-timeout: 1m\nlog-level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SPORTS_LOG_LEVEL", "warn")
+	defer os.Unsetenv("SPORTS_LOG_LEVEL")
+
+	cfg, _, err := load([]string{"sports", "--config", path, "--grpc-endpoint", ":10001"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default", cfg.DBSeed, true},
+		{"file", cfg.DBPath, "/data/file.db"},
+		{"file", cfg.ShutdownTimeout, time.Minute},
+		{"env over file", cfg.LogLevel, "warn"},
+		{"flag", cfg.GRPCEndpoint, ":10001"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoad_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "validation",
+			args:    []string{"--grpc-endpoint", "nope", "--shutdown-timeout", "0s"},
+			wantErr: "invalid config: grpc-endpoint:",
+		},
+		{
+			name:    "client ca without certificate",
+			args:    []string{"--tls-client-ca-file", "ca.pem"},
+			wantErr: "tls-client-ca-file: requires tls-cert-file",
+		},
+		{
+			name:    "unknown exporter",
+			args:    []string{"--trace-exporter", "jaeger"},
+			wantErr: `trace-exporter: unknown exporter "jaeger"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := load(append([]string{"sports"}, tt.args...))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var buf bytes.Buffer
+	if err := settings.Print(&buf, Default()); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	// The printed config must load back to the same values.
+	path := filepath.Join(t.TempDir(), "sports.yaml")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := load([]string{"sports", "--config", path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if *cfg != *Default() {
+		t.Errorf("Load() = %+v, want %+v", cfg, Default())
+	}
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"time"
+
+	"syreclabs.com/go/faker"
+)
+
+// sportNames are the sports dummy events are seeded with.
+var sportNames = []string{"AFL", "Basketball", "Cricket", "NRL", "Soccer", "Tennis"}
+
+func (r *eventsRepo) createSchema() error {
+	_, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS events (id INTEGER PRIMARY KEY, name TEXT, sport TEXT, visible INTEGER, advertised_start_time DATETIME)`)
+
+	return err
+}
+
+func (r *eventsRepo) seed() error {
+	statement, err := r.db.Prepare(`INSERT OR IGNORE INTO events(id, name, sport, visible, advertised_start_time) VALUES (?,?,?,?,?)`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for i := 1; i <= 100; i++ {
+		_, err = statement.Exec(
+			i,
+			faker.Team().Name()+" vs "+faker.Team().Name(),
+			faker.RandomChoice(sportNames),
+			faker.Number().Between(0, 1),
+			faker.Time().Between(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/proto/sports"
+)
+
+// eventsList selects every event, to be filtered, ordered and limited by List.
+const eventsList = `
+	SELECT
+		id,
+		name,
+		sport,
+		visible,
+		advertised_start_time
+	FROM events
+`
+
+// EventsRepo provides repository access to sports events.
+type EventsRepo interface {
+	// Init will initialise our events repository.
+	Init() error
+
+	// List will return the events matching filter in advertised start time
+	// order, at most limit of them unless limit is 0.
+	List(ctx context.Context, filter *sports.ListEventsRequestFilter, limit int) ([]*sports.Event, error)
+}
+
+type eventsRepo struct {
+	db            *sql.DB
+	init          sync.Once
+	seedDummyData bool
+}
+
+// NewEventsRepo creates a new events repository, which seeds the DB with dummy events when seed is set.
+func NewEventsRepo(db *sql.DB, seed bool) EventsRepo {
+	return &eventsRepo{db: db, seedDummyData: seed}
+}
+
+// Init prepares the events repository schema and, if enabled, dummy data.
+func (r *eventsRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		if err = r.createSchema(); err != nil {
+			return
+		}
+
+		// For test/example purposes, we seed the DB with some dummy events.
+		if r.seedDummyData {
+			err = r.seed()
+		}
+	})
+
+	return err
+}
+
+func (r *eventsRepo) List(ctx context.Context, filter *sports.ListEventsRequestFilter, limit int) ([]*sports.Event, error) {
+	query, args := r.applyFilter(eventsList, filter)
+
+	// Compared as datetimes, as stored times carry differing UTC offsets.
+	query += " ORDER BY datetime(advertised_start_time), id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanEvents(rows)
+}
+
+func (r *eventsRepo) applyFilter(query string, filter *sports.ListEventsRequestFilter) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	if filter == nil {
+		return query, args
+	}
+
+	if filter.Visible != nil {
+		clauses = append(clauses, "visible = "+strconv.FormatBool(filter.GetVisible()))
+	}
+	if filter.AdvertisedStartTimeFrom != nil {
+		clauses = append(clauses, "datetime(advertised_start_time) >= datetime(?)")
+		args = append(args, filter.AdvertisedStartTimeFrom.AsTime().UTC().Format(time.RFC3339))
+	}
+
+	if len(clauses) != 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	return query, args
+}
+
+func (r *eventsRepo) scanEvents(rows *sql.Rows) ([]*sports.Event, error) {
+	var events []*sports.Event
+
+	for rows.Next() {
+		var event sports.Event
+		var advertisedStart time.Time
+
+		if err := rows.Scan(&event.Id, &event.Name, &event.Sport, &event.Visible, &advertisedStart); err != nil {
+			return nil, err
+		}
+
+		event.AdvertisedStartTime = timestamppb.New(advertisedStart)
+
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"git.neds.sh/matty/entain/proto/sports"
+	"git.neds.sh/matty/entain/sports/db/dbtest"
+)
+
+func Test_eventsRepo_List(t *testing.T) {
+	db := dbtest.Open(t)
+
+	r := &eventsRepo{db: db}
+	if err := r.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// Stored with differing offsets, 1 and 3 start before 10:00 UTC.
+	if _, err := db.Exec(`INSERT INTO events (id, name, sport, visible, advertised_start_time) VALUES
+		(1, 'A', 'AFL', 1, '2021-03-01T19:59:59+10:00'),
+		(2, 'B', 'AFL', 1, '2021-03-01T20:00:00+10:00'),
+		(3, 'C', 'NRL', 1, '2021-03-01T09:30:00Z'),
+		(4, 'D', 'NRL', 0, '2021-03-01T10:30:00Z'),
+		(5, 'E', 'NRL', 1, '2021-03-01T10:15:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+
+	from := timestamppb.New(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name   string
+		filter *sports.ListEventsRequestFilter
+		limit  int
+		want   []int64
+	}{
+		{name: "all in start time order", want: []int64{3, 1, 2, 5, 4}},
+		{name: "visible", filter: &sports.ListEventsRequestFilter{Visible: proto.Bool(true)}, want: []int64{3, 1, 2, 5}},
+		{name: "hidden", filter: &sports.ListEventsRequestFilter{Visible: proto.Bool(false)}, want: []int64{4}},
+		{name: "from", filter: &sports.ListEventsRequestFilter{AdvertisedStartTimeFrom: from}, want: []int64{2, 5, 4}},
+		{name: "visible from, limited", filter: &sports.ListEventsRequestFilter{Visible: proto.Bool(true), AdvertisedStartTimeFrom: from}, limit: 1, want: []int64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := r.List(context.Background(), tt.filter, tt.limit)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+
+			var got []int64
+			for _, event := range events {
+				got = append(got, event.Id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+module git.neds.sh/matty/entain/sports
+
+go 1.16
+
+require (
+	git.neds.sh/matty/entain/pkg v0.0.0
+	git.neds.sh/matty/entain/proto v0.0.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/sirupsen/logrus v1.8.1
+	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.25.0
+	google.golang.org/grpc v1.41.0
+	google.golang.org/protobuf v1.27.1
+	syreclabs.com/go/faker v1.2.3
+)
+
+replace (
+	git.neds.sh/matty/entain/pkg => ../pkg
+	git.neds.sh/matty/entain/proto => ../proto
+)
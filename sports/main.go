@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.neds.sh/matty/entain/pkg/grpcserver/deadline"
+	"git.neds.sh/matty/entain/pkg/grpcserver/health"
+	"git.neds.sh/matty/entain/pkg/grpcserver/logging"
+	"git.neds.sh/matty/entain/pkg/grpcserver/metrics"
+	"git.neds.sh/matty/entain/pkg/grpcserver/recovery"
+	"git.neds.sh/matty/entain/pkg/grpcserver/validation"
+	"git.neds.sh/matty/entain/pkg/settings"
+	"git.neds.sh/matty/entain/pkg/tlsutil"
+	"git.neds.sh/matty/entain/pkg/tracing"
+	"git.neds.sh/matty/entain/proto/sports"
+	"git.neds.sh/matty/entain/sports/config"
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/service"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+)
+
+func main() {
+	cfg := config.Default()
+	printConfig, err := settings.LoadConfig(os.Args, config.EnvPrefix, cfg)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		logrus.Fatalf("failed loading config: %s", err)
+	}
+
+	if printConfig {
+		if err := settings.Print(os.Stdout, cfg); err != nil {
+			logrus.Fatalf("failed printing config: %s", err)
+		}
+		return
+	}
+
+	logger, err := logging.NewLogger(cfg.LogLevel)
+	if err != nil {
+		logrus.Fatalf("invalid log level: %s", err)
+	}
+
+	if err := run(cfg, logger); err != nil {
+		logger.WithError(err).Fatal("failed running grpc server")
+	}
+}
+
+// metricsShutdownTimeout bounds closing the metrics server, once the main
+// server has drained.
+const metricsShutdownTimeout = 5 * time.Second
+
+func run(cfg *config.Config, logger *logrus.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, "sports", cfg.TraceExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.WithError(err).Warn("failed flushing traces")
+		}
+	}()
+
+	conn, err := net.Listen("tcp", cfg.GRPCEndpoint)
+	if err != nil {
+		return err
+	}
+
+	sportsDB, err := sql.Open("sqlite3", cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer sportsDB.Close()
+
+	metrics.RegisterDBStats("sports", sportsDB)
+
+	eventsRepo := db.NewEventsRepo(sportsDB, cfg.DBSeed)
+
+	healthChecker := health.NewChecker(sportsDB, cfg.HealthInterval, sports.Sports_ServiceDesc.ServiceName)
+
+	serverOpts, err := tlsutil.GRPCServerOptions(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return err
+	}
+
+	deadlines := deadline.Options{
+		Timeouts: deadline.Timeouts{Default: cfg.RPCDefaultTimeout, Max: cfg.RPCMaxTimeout},
+	}
+
+	// Inside logging and metrics, so recovered panics are logged and counted
+	// as Internal errors.
+	onPanic := []recovery.Handler{logging.LogPanic, func(_ context.Context, method string, _ interface{}, _ []byte) {
+		metrics.RecordPanic(method)
+	}}
+
+	grpcServer := grpc.NewServer(append(serverOpts,
+		// Let gateways ping idle connections to keep them alive, as long as
+		// they don't ping more often than this.
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			logging.UnaryServerInterceptor(logger),
+			metrics.UnaryServerInterceptor(),
+			recovery.UnaryServerInterceptor(onPanic...),
+			deadline.UnaryServerInterceptor(deadlines),
+			validation.UnaryServerInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(),
+			logging.StreamServerInterceptor(logger),
+			metrics.StreamServerInterceptor(),
+			recovery.StreamServerInterceptor(onPanic...),
+			deadline.StreamServerInterceptor(deadlines),
+			validation.StreamServerInterceptor(),
+		),
+	)...)
+
+	sports.RegisterSportsServer(grpcServer, service.NewSportsService(eventsRepo))
+	healthpb.RegisterHealthServer(grpcServer, healthChecker.Server())
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+
+	metricsServer := &http.Server{Addr: cfg.MetricsEndpoint, Handler: metricsMux}
+
+	go func() {
+		logger.Infof("metrics server listening on: %s", cfg.MetricsEndpoint)
+
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Error("failed running metrics server")
+		}
+	}()
+
+	logger.WithFields(logrus.Fields{
+		"tls":        cfg.TLSCertFile != "",
+		"mutual_tls": cfg.TLSClientCAFile != "",
+	}).Infof("gRPC server listening on: %s", cfg.GRPCEndpoint)
+
+	// The repository is ready before serving, so no RPC can reach a half
+	// initialised database.
+	if err := eventsRepo.Init(); err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(conn)
+	}()
+
+	healthChecker.SetReady(ctx)
+	go healthChecker.Watch(ctx)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Restore default signal handling, so a second signal kills the process
+	// rather than waiting out the drain.
+	stop()
+
+	logger.Info("shutting down, draining in-flight requests")
+
+	// Report NOT_SERVING first, so traffic is routed away before draining.
+	healthChecker.Shutdown()
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-drainCtx.Done():
+		logger.Warn("drain timeout exceeded, closing remaining connections")
+		grpcServer.Stop()
+	}
+
+	// The drain may have used up drainCtx, so the metrics server gets its own.
+	metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancelMetrics()
+
+	if err := metricsServer.Shutdown(metricsCtx); err != nil {
+		logger.WithError(err).Warn("failed shutting down metrics server")
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/proto/sports"
+)
+
+// recordingEventsRepo returns events, recording the arguments of the last List.
+type recordingEventsRepo struct {
+	events []*sports.Event
+
+	filter *sports.ListEventsRequestFilter
+	limit  int
+}
+
+func (r *recordingEventsRepo) Init() error { return nil }
+
+func (r *recordingEventsRepo) List(_ context.Context, filter *sports.ListEventsRequestFilter, limit int) ([]*sports.Event, error) {
+	r.filter, r.limit = filter, limit
+	return r.events, nil
+}
+
+func Test_sportsService_ListEvents(t *testing.T) {
+	events := []*sports.Event{{Id: 1, Name: "Richmond vs Carlton"}}
+	filter := &sports.ListEventsRequestFilter{Visible: proto.Bool(true)}
+
+	tests := []struct {
+		name      string
+		in        *sports.ListEventsRequest
+		want      []*sports.Event
+		wantLimit int
+	}{
+		{name: "unlimited", in: &sports.ListEventsRequest{Filter: filter}, want: events},
+		{name: "page size", in: &sports.ListEventsRequest{Filter: filter, PageSize: 10}, want: events, wantLimit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &recordingEventsRepo{events: events}
+
+			resp, err := NewSportsService(repo).ListEvents(context.Background(), tt.in)
+			if err != nil {
+				t.Fatalf("ListEvents() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(resp.Events, tt.want) {
+				t.Errorf("ListEvents() events = %v, want %v", resp.Events, tt.want)
+			}
+			if repo.filter != tt.in.Filter || repo.limit != tt.wantLimit {
+				t.Errorf("List() called with filter %v, limit %d, want %v, %d", repo.filter, repo.limit, tt.in.Filter, tt.wantLimit)
+			}
+		})
+	}
+}